@@ -0,0 +1,10 @@
+// Package assets embeds the templates and images served by httpbin-go so
+// the binary doesn't depend on its working directory.
+package assets
+
+import "embed"
+
+// FS holds the templates and images directories
+//
+//go:embed templates images
+var FS embed.FS
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/nathanows/httpbin-go/internal/app/httpbin"
 
@@ -9,12 +10,19 @@ import (
 )
 
 func main() {
+	config, err := httpbin.LoadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Unable to load config. Err: %+v", err)
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
 
-	server, err := httpbin.NewServer(router)
+	server, err := httpbin.NewServer(router, httpbin.WithConfig(config))
 	if err != nil {
 		log.Fatalf("Unable to setup server. Err: %+v", err)
 	}
 
-	server.ListenAndServe()
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Server failed. Err: %+v", err)
+	}
 }
@@ -0,0 +1,179 @@
+package httpbin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Access log formats
+const (
+	AccessLogNone     = "none"
+	AccessLogCommon   = "common"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+)
+
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogger writes one line per request in the configured format
+type accessLogger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format string
+}
+
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+func newAccessLogger(format string, out io.Writer) (*accessLogger, error) {
+	switch format {
+	case AccessLogCommon, AccessLogCombined, AccessLogJSON:
+		return &accessLogger{out: out, format: format}, nil
+	}
+	return nil, fmt.Errorf("unknown access log format: %s", format)
+}
+
+func (l *accessLogger) log(e *accessLogEntry) {
+	var line []byte
+	switch l.format {
+	case AccessLogJSON:
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	case AccessLogCommon:
+		line = []byte(e.common() + "\n")
+	case AccessLogCombined:
+		line = []byte(fmt.Sprintf("%s %q %q\n", e.common(), dashIfEmpty(e.Referer), dashIfEmpty(e.UserAgent)))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
+
+// common formats the entry in the Common Log Format
+func (e *accessLogEntry) common() string {
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		e.RemoteAddr, dashIfEmpty(e.User), e.Time.Format(clfTimeFormat), e.Method, e.Path, e.Proto, e.Status, size)
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// logAccess assigns each request an ID, returned in the X-Request-Id header
+// unless the client supplied one, and writes an access log line once the
+// response has been written
+func (s *Server) logAccess(next http.Handler) http.Handler {
+	if s.accessLogger == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := s.now()
+
+		requestID := r.Header.Get("X-Request-Id")
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set("X-Request-Id", requestID)
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		user, _, _ := r.BasicAuth()
+		remoteAddr := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+			remoteAddr = host
+		}
+		s.accessLogger.log(&accessLogEntry{
+			Time:       start,
+			RequestID:  requestID,
+			RemoteAddr: remoteAddr,
+			User:       user,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Proto:      r.Proto,
+			Status:     rw.statusCode(),
+			Bytes:      rw.bytes,
+			DurationMS: float64(s.now().Sub(start)) / float64(time.Millisecond),
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+		})
+	})
+}
+
+// recordingWriter records the status code and number of bytes written
+// while passing through flushing and hijacking
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+func (rw *recordingWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *recordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *recordingWriter) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
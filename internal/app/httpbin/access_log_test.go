@@ -0,0 +1,85 @@
+package httpbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// accessLogClock fixes the time written to the access log
+var accessLogClock = WithClock(func() time.Time { return time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC) })
+
+func TestAccessLog_Common(t *testing.T) {
+	out := &bytes.Buffer{}
+	server := newTestServer(t, WithConfig(&Config{AccessLog: AccessLogCommon}), WithAccessLogOutput(out), accessLogClock)
+
+	r := httptest.NewRequest("GET", "http://test.com/status/418?x=1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.SetBasicAuth("user", "passwd")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, r)
+
+	expected := `10.0.0.1 - user [01/Mar/2024:12:00:00 +0000] "GET /status/418?x=1 HTTP/1.1" 418 `
+	if line := out.String(); !strings.HasPrefix(line, expected) || !strings.HasSuffix(line, "\n") {
+		t.Errorf("Expected log line to start with %q, got: %q", expected, line)
+	}
+	if w.Header().Get("X-Request-Id") == "" {
+		t.Errorf("Expected X-Request-Id header to be set")
+	}
+}
+
+func TestAccessLog_Combined(t *testing.T) {
+	out := &bytes.Buffer{}
+	server := newTestServer(t, WithConfig(&Config{AccessLog: AccessLogCombined}), WithAccessLogOutput(out), accessLogClock)
+
+	r := httptest.NewRequest("GET", "http://test.com/headers", nil)
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", "test-agent")
+	server.Handler().ServeHTTP(httptest.NewRecorder(), r)
+
+	if line := out.String(); !strings.HasSuffix(line, `"http://example.com/" "test-agent"`+"\n") {
+		t.Errorf("Expected referer and user agent at end of log line, got: %q", line)
+	}
+}
+
+func TestAccessLog_JSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	server := newTestServer(t, WithConfig(&Config{AccessLog: AccessLogJSON}), WithAccessLogOutput(out), accessLogClock)
+
+	r := httptest.NewRequest("POST", "http://test.com/post", strings.NewReader("hello"))
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("X-Request-Id", "abc-123")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, r)
+
+	var entry accessLogEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse log line %q. Err: %v", out.String(), err)
+	}
+	if entry.Method != "POST" || entry.Path != "/post" || entry.Status != http.StatusOK {
+		t.Errorf("Unexpected method, path or status in log entry: %+v", entry)
+	}
+	if entry.Bytes != int64(w.Body.Len()) {
+		t.Errorf("Expected bytes to be %d, got: %d", w.Body.Len(), entry.Bytes)
+	}
+	if entry.RequestID != "abc-123" || w.Header().Get("X-Request-Id") != "abc-123" {
+		t.Errorf("Expected client request ID to be used, got: %s", entry.RequestID)
+	}
+	if entry.UserAgent != "test-agent" {
+		t.Errorf("Expected user agent to be test-agent, got: %s", entry.UserAgent)
+	}
+}
+
+func TestAccessLog_UnknownFormat(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessLog = "apache"
+	if _, err := NewServer(mux.NewRouter(), WithConfig(config)); err == nil {
+		t.Errorf("Expected an error for an unknown access log format")
+	}
+}
@@ -0,0 +1,126 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// verifyRequest asks how many requests served by mocks match a request
+// matcher, optionally limited to one mock
+type verifyRequest struct {
+	Mock    string       `json:"mock,omitempty"`
+	Request *mockRequest `json:"request,omitempty"`
+	// Count is the expected number of calls, when omitted at least one
+	// call is expected
+	Count *int `json:"count,omitempty"`
+}
+
+type verifyResponse struct {
+	Verified bool        `json:"verified"`
+	Expected *int        `json:"expected,omitempty"`
+	Actual   int         `json:"actual"`
+	Calls    []*mockCall `json:"calls"`
+}
+
+func (s *Server) handleCreateMock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m, err := s.newMock(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mocks.add(m)
+		writeJSON(w, http.StatusCreated, m)
+	}
+}
+
+func (s *Server) handleListMocks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"mocks": s.mocks.list()})
+	}
+}
+
+func (s *Server) handleResetMocks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mocks.reset()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleGetMock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := s.mocks.get(mux.Vars(r)["id"])
+		if m == nil {
+			http.Error(w, "mock not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	}
+}
+
+func (s *Server) handleDeleteMock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.mocks.remove(mux.Vars(r)["id"]) {
+			http.Error(w, "mock not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handleMockRequests() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"requests": s.mocks.calls()})
+	}
+}
+
+// handleVerify answers 200 when the expectation holds and 417 Expectation
+// Failed otherwise, so scripts can check the status alone
+func (s *Server) handleVerify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req verifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid verification: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Request != nil {
+			if err := req.Request.compile(s.basePath()); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		resp := verifyResponse{Expected: req.Count, Calls: []*mockCall{}}
+		for _, call := range s.mocks.calls() {
+			if req.Mock != "" && call.MockID != req.Mock {
+				continue
+			}
+			body := call.Body
+			if req.Request != nil && !req.Request.match(call.request(), func() string { return body }) {
+				continue
+			}
+			resp.Calls = append(resp.Calls, call)
+		}
+		resp.Actual = len(resp.Calls)
+		if req.Count != nil {
+			resp.Verified = resp.Actual == *req.Count
+		} else {
+			resp.Verified = resp.Actual > 0
+		}
+
+		code := http.StatusOK
+		if !resp.Verified {
+			code = http.StatusExpectationFailed
+		}
+		writeJSON(w, code, resp)
+	}
+}
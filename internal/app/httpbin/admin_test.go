@@ -0,0 +1,210 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func createMock(t *testing.T, handler http.Handler, spec string) mock {
+	w := serve(handler, "POST", "/_admin/mocks", spec)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got: %d %s", w.Code, w.Body)
+	}
+	var m mock
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatalf("Failed to parse mock. Err: %v", err)
+	}
+	return m
+}
+
+func TestMocks_Match(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{Admin: true})).Handler()
+	createMock(t, handler, `{
+		"request": {
+			"method": "POST",
+			"path": "/users/{id:[0-9]+}",
+			"headers": {"X-Api-Key": "secret"},
+			"query": {"dry_run": {"absent": true}},
+			"body": {"json": {"name": "ann"}}
+		},
+		"response": {"status": 201, "headers": {"Location": "/users/1"}, "json": {"id": 1}}
+	}`)
+
+	r := httptest.NewRequest("POST", "http://test.com/users/1", strings.NewReader(`{ "name": "ann" }`))
+	r.Header.Set("X-Api-Key", "secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"id":1}` || w.Header().Get("Location") != "/users/1" {
+		t.Errorf("Expected mock response, got: %d %s", w.Code, w.Body)
+	}
+
+	testCases := []struct {
+		target string
+		header string
+		body   string
+	}{
+		{"/users/1", "wrong", `{"name":"ann"}`},
+		{"/users/1?dry_run=1", "secret", `{"name":"ann"}`},
+		{"/users/abc", "secret", `{"name":"ann"}`},
+		{"/users/1", "secret", `{"name":"bob"}`},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest("POST", "http://test.com"+tc.target, strings.NewReader(tc.body))
+		r.Header.Set("X-Api-Key", tc.header)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code == http.StatusCreated {
+			t.Errorf("Expected %s with key %s and body %s not to match", tc.target, tc.header, tc.body)
+		}
+	}
+}
+
+func TestMocks_OverrideAndTimes(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{Admin: true})).Handler()
+	createMock(t, handler, `{"request": {"path": "/get"}, "response": {"status": 503, "body": "down"}, "times": 2}`)
+
+	for i := 0; i < 2; i++ {
+		if w := serve(handler, "GET", "/get", ""); w.Code != http.StatusServiceUnavailable || w.Body.String() != "down" {
+			t.Errorf("Expected call %d to be mocked, got: %d", i, w.Code)
+		}
+	}
+	if w := serve(handler, "GET", "/get", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected built-in /get once mock was used up, got: %d", w.Code)
+	}
+}
+
+func TestMocks_UsedUpAfterMatch(t *testing.T) {
+	server := newTestServer(t, WithConfig(&Config{Admin: true}))
+	createMock(t, server.Handler(), `{"request": {"path": "/get"}, "response": {"status": 503}, "times": 1}`)
+
+	// another request uses up the mock between matching and serving
+	r := httptest.NewRequest("GET", "http://test.com/get", nil)
+	if !server.matchMock(r, &mux.RouteMatch{}) {
+		t.Fatalf("Expected the mock to match")
+	}
+	server.mocks.serve(r, nil, server.now())
+
+	w := httptest.NewRecorder()
+	server.handleMock().ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"url"`) {
+		t.Errorf("Expected built-in /get once the mock was used up, got: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestMocks_BodyTooLarge(t *testing.T) {
+	config := &Config{Admin: true, MaxBodySize: 128}
+	server := newTestServer(t, WithConfig(config))
+	handler := server.Handler()
+	createMock(t, handler, `{"request": {"path": "/post", "body": {"contains": "a"}}, "response": {"status": 503}}`)
+
+	// a chunked body has no Content-Length, so matching must stop reading
+	// once it passes the limit
+	body := strings.NewReader(strings.Repeat("a", 1<<20))
+	r := httptest.NewRequest("POST", "http://test.com/post", body)
+	r.ContentLength = -1
+	if server.matchMock(r, &mux.RouteMatch{}) {
+		t.Errorf("Expected an over-limit body to match no mock")
+	}
+	if read := body.Size() - int64(body.Len()); read > config.MaxBodySize+1 {
+		t.Errorf("Expected at most %d bytes read while matching, got: %d", config.MaxBodySize+1, read)
+	}
+
+	r = httptest.NewRequest("POST", "http://test.com/post", strings.NewReader(strings.Repeat("a", 1<<20)))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got: %d %s", w.Code, w.Body)
+	}
+	if w := serve(handler, "GET", "/_admin/requests", ""); strings.Contains(w.Body.String(), "/post") {
+		t.Errorf("Expected no recorded mock call, got: %s", w.Body)
+	}
+}
+
+func TestMocks_Verify(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{Admin: true})).Handler()
+	m := createMock(t, handler, `{"request": {"method": "POST", "path": "/hooks"}}`)
+
+	serve(handler, "POST", "/hooks", `{"event":"push"}`)
+	serve(handler, "POST", "/hooks", `{"event":"push"}`)
+	serve(handler, "POST", "/hooks", `{"event":"tag"}`)
+
+	testCases := []struct {
+		verify string
+		code   int
+		actual int
+	}{
+		{`{"mock": "` + m.ID + `", "count": 3}`, http.StatusOK, 3},
+		{`{"request": {"path": "/hooks", "body": {"contains": "push"}}, "count": 2}`, http.StatusOK, 2},
+		{`{"request": {"body": {"json": {"event": "tag"}}}, "count": 2}`, http.StatusExpectationFailed, 1},
+		{`{"request": {"path": "/other"}}`, http.StatusExpectationFailed, 0},
+	}
+	for _, tc := range testCases {
+		w := serve(handler, "POST", "/_admin/verify", tc.verify)
+		var resp verifyResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != tc.code || resp.Actual != tc.actual {
+			t.Errorf("Expected %d with %d calls for %s, got: %d with %d", tc.code, tc.actual, tc.verify, w.Code, resp.Actual)
+		}
+	}
+
+	if w := serve(handler, "GET", "/_admin/mocks/"+m.ID, ""); !strings.Contains(w.Body.String(), `"calls": 3`) {
+		t.Errorf("Expected mock to report 3 calls, got: %s", w.Body)
+	}
+}
+
+func TestMocks_ListDeleteAndReset(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{Admin: true})).Handler()
+	m := createMock(t, handler, `{"request": {"path": "/a"}}`)
+	createMock(t, handler, `{"request": {"path": "/b"}}`)
+
+	var list struct {
+		Mocks []mock `json:"mocks"`
+	}
+	json.Unmarshal(serve(handler, "GET", "/_admin/mocks", "").Body.Bytes(), &list)
+	if len(list.Mocks) != 2 || list.Mocks[0].Request.Path != "/b" {
+		t.Errorf("Expected 2 mocks, newest first, got: %+v", list.Mocks)
+	}
+
+	if w := serve(handler, "DELETE", "/_admin/mocks/"+m.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got: %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/a", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected deleted mock to stop matching, got: %d", w.Code)
+	}
+
+	serve(handler, "GET", "/b", "")
+	serve(handler, "DELETE", "/_admin/mocks", "")
+	if w := serve(handler, "GET", "/b", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected reset to remove all mocks, got: %d", w.Code)
+	}
+	if w := serve(handler, "GET", "/_admin/requests", ""); !strings.Contains(w.Body.String(), `"requests": []`) {
+		t.Errorf("Expected reset to clear the journal, got: %s", w.Body)
+	}
+}
+
+func TestMocks_Invalid(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{Admin: true})).Handler()
+	for _, spec := range []string{
+		`{"request": {}}`,
+		`{"request": {"path": "users"}}`,
+		`{"request": {"path": "/a", "body": {"matches": "("}}}`,
+		`{"request": {"path": "/a"}, "response": {"status": 7}}`,
+	} {
+		if w := serve(handler, "POST", "/_admin/mocks", spec); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got: %d", spec, w.Code)
+		}
+	}
+}
+
+func TestMocks_AdminDisabled(t *testing.T) {
+	server := newTestServer(t)
+	if w := serve(server.Handler(), "POST", "/_admin/mocks", `{"request": {"path": "/a"}}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected admin API to be disabled by default, got: %d", w.Code)
+	}
+}
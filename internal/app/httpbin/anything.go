@@ -4,7 +4,7 @@ import "net/http"
 
 func (s *Server) handleAnything() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		keys := requestKeys{"args", "data", "files", "form", "headers", "json", "method", "origin", "url"}
-		returnRequestAsJSON(w, r, keys)
+		keys := requestKeys{"args", "data", "files", "form", "headers", "json", "method", "origin", "protocol", "http2", "url"}
+		s.returnRequestAsJSON(w, r, keys)
 	}
 }
@@ -27,7 +27,7 @@ func TestAnything(t *testing.T) {
 	if err := req.runTestCases(testCases); err != nil {
 		t.Errorf("Failed test case. Failure: %v", err)
 	}
-	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "method", "origin", "url"}
+	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "method", "origin", "protocol", "url"}
 	if err := req.validateCorrectFields(expectedResponseKeys); err != nil {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
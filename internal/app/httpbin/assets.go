@@ -0,0 +1,102 @@
+package httpbin
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"text/template"
+
+	"github.com/nathanows/httpbin-go/assets"
+)
+
+// assetSet holds the parsed templates and the images served by the server
+type assetSet struct {
+	templates *template.Template
+	images    fs.FS
+}
+
+// defaultAssets are the embedded assets, used when no override directories
+// are configured
+var defaultAssets = mustLoadAssets("", "")
+
+// loadAssets parses the embedded templates and images, letting files in
+// templatesDir and imagesDir override the embedded ones of the same name
+func loadAssets(templatesDir, imagesDir string) (*assetSet, error) {
+	templatesFS, err := fs.Sub(assets.FS, "templates")
+	if err != nil {
+		return nil, err
+	}
+	imagesFS, err := fs.Sub(assets.FS, "images")
+	if err != nil {
+		return nil, err
+	}
+
+	if templatesDir != "" {
+		templatesFS = overlayFS{upper: os.DirFS(templatesDir), lower: templatesFS}
+	}
+	if imagesDir != "" {
+		imagesFS = overlayFS{upper: os.DirFS(imagesDir), lower: imagesFS}
+	}
+
+	templates, err := template.ParseFS(templatesFS, "*")
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse templates: %v", err)
+	}
+
+	return &assetSet{templates: templates, images: imagesFS}, nil
+}
+
+func mustLoadAssets(templatesDir, imagesDir string) *assetSet {
+	a, err := loadAssets(templatesDir, imagesDir)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// assets returns the server's assets, falling back to the embedded assets
+// for servers that weren't built with NewServer
+func (s *Server) assets() *assetSet {
+	if s.assetSet == nil {
+		return defaultAssets
+	}
+	return s.assetSet
+}
+
+// overlayFS serves files from upper when they exist there and from lower
+// otherwise
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.upper.Open(name); err == nil {
+		return f, nil
+	}
+	return o.lower.Open(name)
+}
+
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	lower, err := fs.ReadDir(o.lower, name)
+	if err != nil {
+		return nil, err
+	}
+	upper, _ := fs.ReadDir(o.upper, name)
+
+	entries := make(map[string]fs.DirEntry, len(lower)+len(upper))
+	for _, e := range lower {
+		entries[e.Name()] = e
+	}
+	for _, e := range upper {
+		entries[e.Name()] = e
+	}
+
+	merged := make([]fs.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		merged = append(merged, e)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name() < merged[j].Name() })
+	return merged, nil
+}
@@ -0,0 +1,59 @@
+package httpbin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestNewServer_AssetOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpbin-assets")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "moby.html"), []byte("<html>Custom</html>"), 0644); err != nil {
+		t.Fatalf("Failed to write template. Err: %v", err)
+	}
+
+	server := newTestServer(t, WithConfig(&Config{TemplatesDir: dir}))
+
+	req := newTestRequest(server.handleHTML(), "http://test.com/html", "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if body := string(req.rawResponse); body != "<html>Custom</html>" {
+		t.Errorf("Expected the overriding template to be rendered, got: %s", body)
+	}
+
+	req = newTestRequest(server.handleXML(), "http://test.com/xml", "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if !strings.Contains(string(req.rawResponse), "Yours Truly") {
+		t.Errorf("Expected templates that aren't overridden to fall back to the embedded ones")
+	}
+}
+
+func TestNewServer_InvalidTemplateOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpbin-assets")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "moby.html"), []byte("{{ .Broken"), 0644); err != nil {
+		t.Fatalf("Failed to write template. Err: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.TemplatesDir = dir
+	if _, err := NewServer(mux.NewRouter(), WithConfig(cfg)); err == nil {
+		t.Errorf("Expected an error for an unparseable template")
+	}
+}
@@ -3,8 +3,10 @@ package httpbin
 import (
 	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 )
@@ -18,34 +20,125 @@ type authResponse struct {
 func (s *Server) handleBasicAuth() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
-		username := vars["user"]
-		password := vars["password"]
-
-		user, pass, ok := r.BasicAuth()
-
-		if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(username)) != 1 || subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Fake Realm"`)
+		if !checkBasicAuth(r, vars["user"], vars["password"]) {
+			query := r.URL.Query()
+			realm := query.Get("realm")
+			if realm == "" {
+				realm = "Fake Realm"
+			}
+			challenge := fmt.Sprintf("Basic realm=%q", realm)
+			if charset := query.Get("charset"); charset != "" {
+				challenge += fmt.Sprintf(", charset=%q", charset)
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
 			w.WriteHeader(401)
 			w.Write([]byte("Unauthorized.\n"))
 			return
 		}
+		writeAuthenticated(w, vars["user"])
+	}
+}
 
-		resp := authResponse{Authenticated: true, User: username}
-		jsonResp, err := json.MarshalIndent(resp, "", "  ")
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+// handleHiddenBasicAuth behaves like handleBasicAuth but answers 404
+// without a challenge, so clients must send credentials preemptively
+func (s *Server) handleHiddenBasicAuth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		if !checkBasicAuth(r, vars["user"], vars["password"]) {
+			http.NotFound(w, r)
 			return
 		}
-		jsonResp = append(jsonResp, "\n"...)
+		writeAuthenticated(w, vars["user"])
+	}
+}
 
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(jsonResp)
+// checkBasicAuth reports whether the request carries the user and password
+// as Basic credentials. Credentials that aren't valid UTF-8 are read as
+// ISO-8859-1, which clients use when no charset was advertised.
+func checkBasicAuth(r *http.Request, username, password string) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
 	}
+	user, pass = latin1ToUTF8(user), latin1ToUTF8(pass)
+	return subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
 }
 
+func latin1ToUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
+
+// handleAPIKey checks for the API key in the path, carried in the header,
+// query parameter or cookie with the given name. Failures answer 401 or,
+// with ?status=403, 403.
+func (s *Server) handleAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		status := http.StatusUnauthorized
+		switch val := r.URL.Query().Get("status"); val {
+		case "", "401":
+		case "403":
+			status = http.StatusForbidden
+		default:
+			http.Error(w, fmt.Sprintf("invalid status %q, expected 401 or 403", val), http.StatusBadRequest)
+			return
+		}
+
+		var key string
+		switch carrier := vars["carrier"]; carrier {
+		case "header":
+			key = r.Header.Get(vars["name"])
+		case "query":
+			key = r.URL.Query().Get(vars["name"])
+		case "cookie":
+			if cookie, err := r.Cookie(vars["name"]); err == nil {
+				key = cookie.Value
+			}
+		default:
+			http.Error(w, fmt.Sprintf("invalid carrier %q, expected header, query or cookie", carrier), http.StatusBadRequest)
+			return
+		}
+
+		if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(vars["key"])) != 1 {
+			writeJSON(w, status, authResponse{})
+			return
+		}
+		writeJSON(w, http.StatusOK, authResponse{Authenticated: true, Token: key})
+	}
+}
+
+func writeAuthenticated(w http.ResponseWriter, user string) {
+	resp := authResponse{Authenticated: true, User: user}
+	jsonResp, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonResp = append(jsonResp, "\n"...)
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// handleBearer accepts any bearer token or, with ?mode=jwt, validates it
+// like /jwt
 func (s *Server) handleBearer() http.HandlerFunc {
+	jwt := s.handleJWT()
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("mode") == "jwt" {
+			jwt(w, r)
+			return
+		}
+
 		var token string
 
 		tokens, ok := r.Header["Authorization"]
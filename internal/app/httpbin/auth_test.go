@@ -72,3 +72,103 @@ func TestHandleBearer(t *testing.T) {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
 }
+
+func TestHandleBasicAuth_Challenge(t *testing.T) {
+	target := "http://test.com/basic-auth/user/passwd?realm=Devices&charset=UTF-8"
+	req := newTestRequest(authServer.handleBasicAuth(), target, "GET", testReqStatus([]int{401}))
+	req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"user": "user", "password": "passwd"})
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.response.Header().Get("WWW-Authenticate"); val != `Basic realm="Devices", charset="UTF-8"` {
+		t.Errorf("Unexpected challenge: %s", val)
+	}
+}
+
+func TestHandleBasicAuth_NonASCII(t *testing.T) {
+	user := "jürgen"
+	pass := "pässwörd"
+	latin1 := func(s string) string {
+		var b []byte
+		for _, r := range s {
+			b = append(b, byte(r))
+		}
+		return string(b)
+	}
+
+	for _, creds := range []string{user + ":" + pass, latin1(user + ":" + pass)} {
+		authHeader := "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+		headers := map[string][]string{"Authorization": {authHeader}}
+		req := newTestRequest(authServer.handleBasicAuth(), "http://test.com/basic-auth/j%C3%BCrgen/p%C3%A4ssw%C3%B6rd", "GET", testReqHeaders(headers))
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"user": user, "password": pass})
+		if err := req.make(); err != nil {
+			t.Errorf("Failed to make request. Err: %v", err)
+		}
+		if err := req.validateStatusCode(); err != nil {
+			t.Errorf("Failed request base validations for %q. Failure: %v", creds, err)
+		}
+	}
+}
+
+func TestHandleHiddenBasicAuth(t *testing.T) {
+	target := "http://test.com/hidden-basic-auth/user/passwd"
+	req := newTestRequest(authServer.handleHiddenBasicAuth(), target, "GET", testReqStatus([]int{404}))
+	req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"user": "user", "password": "passwd"})
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.response.Header().Get("WWW-Authenticate"); val != "" {
+		t.Errorf("Expected no challenge, got: %s", val)
+	}
+
+	headers := map[string][]string{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("user:passwd"))}}
+	req = newTestRequest(authServer.handleHiddenBasicAuth(), target, "GET", testReqHeaders(headers))
+	req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"user": "user", "password": "passwd"})
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.parsedJSON.Path("authenticated").Data(); val != true {
+		t.Errorf("Expected 'authenticated' to be 'true', got: %v", val)
+	}
+}
+
+func TestHandleAPIKey(t *testing.T) {
+	testCases := []struct {
+		target  string
+		vars    map[string]string
+		headers map[string][]string
+		status  int
+	}{
+		{"http://test.com/api-key/header/X-API-Key/abc", map[string]string{"carrier": "header", "name": "X-API-Key", "key": "abc"}, map[string][]string{"X-Api-Key": {"abc"}}, 200},
+		{"http://test.com/api-key/header/X-API-Key/abc", map[string]string{"carrier": "header", "name": "X-API-Key", "key": "abc"}, map[string][]string{"X-Api-Key": {"abd"}}, 401},
+		{"http://test.com/api-key/query/api_key/abc?api_key=abc", map[string]string{"carrier": "query", "name": "api_key", "key": "abc"}, nil, 200},
+		{"http://test.com/api-key/query/api_key/abc?status=403", map[string]string{"carrier": "query", "name": "api_key", "key": "abc"}, nil, 403},
+		{"http://test.com/api-key/cookie/session/abc", map[string]string{"carrier": "cookie", "name": "session", "key": "abc"}, map[string][]string{"Cookie": {"session=abc"}}, 200},
+		{"http://test.com/api-key/cookie/session/abc", map[string]string{"carrier": "cookie", "name": "session", "key": "abc"}, map[string][]string{"X-Session": {"abc"}}, 401},
+		{"http://test.com/api-key/body/api_key/abc", map[string]string{"carrier": "body", "name": "api_key", "key": "abc"}, nil, 400},
+	}
+	for _, tc := range testCases {
+		req := newTestRequest(authServer.handleAPIKey(), tc.target, "GET", testReqHeaders(tc.headers), testReqStatus([]int{tc.status}))
+		req.baseRequest = mux.SetURLVars(req.baseRequest, tc.vars)
+		if err := req.make(); err != nil {
+			t.Errorf("Failed to make request. Err: %v", err)
+		}
+		if err := req.validateStatusCode(); err != nil {
+			t.Errorf("Failed request base validations for %s. Failure: %v", tc.target, err)
+		}
+		if tc.status == 200 {
+			if val := req.parsedJSON.Path("token").Data(); val != "abc" {
+				t.Errorf("Expected 'token' to be 'abc', got: %v", val)
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package httpbin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Bin storage backends
+const (
+	BinStoreMemory = "memory"
+	BinStoreFile   = "file"
+)
+
+// maxMemoryBins caps the number of bins held by the memory store, the
+// oldest bin is evicted when it is exceeded
+const maxMemoryBins = 1000
+
+var errBinNotFound = errors.New("bin not found")
+
+// binStore persists bins and the requests captured by them
+type binStore interface {
+	// create stores a new bin
+	create(b *bin) error
+	// get returns the bin with the given ID or errBinNotFound
+	get(id string) (*bin, error)
+	// add appends a captured request to a bin
+	add(id string, req *capturedRequest) error
+	// list returns up to limit requests of a bin, oldest first, starting at
+	// offset along with the total number of requests held
+	list(id string, offset, limit int) ([]*capturedRequest, int, error)
+}
+
+func newBinStore(cfg *Config) (binStore, error) {
+	switch cfg.BinStore {
+	case "", BinStoreMemory:
+		return newMemoryBinStore(cfg.BinCapacity), nil
+	case BinStoreFile:
+		if err := os.MkdirAll(cfg.BinDir, 0755); err != nil {
+			return nil, fmt.Errorf("unable to create bin directory: %v", err)
+		}
+		return &fileBinStore{dir: cfg.BinDir}, nil
+	}
+	return nil, fmt.Errorf("unknown bin store: %s", cfg.BinStore)
+}
+
+// memoryBinStore keeps the most recent requests of each bin in a ring
+// buffer
+type memoryBinStore struct {
+	mu       sync.Mutex
+	capacity int
+	bins     map[string]*memoryBin
+	order    []string
+}
+
+type memoryBin struct {
+	bin      *bin
+	requests []*capturedRequest
+	// start is the index of the oldest request once the buffer is full
+	start int
+}
+
+func newMemoryBinStore(capacity int) *memoryBinStore {
+	if capacity <= 0 {
+		capacity = DefaultBinCapacity
+	}
+	return &memoryBinStore{
+		capacity: capacity,
+		bins:     make(map[string]*memoryBin),
+	}
+}
+
+func (m *memoryBinStore) create(b *bin) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.order) >= maxMemoryBins {
+		delete(m.bins, m.order[0])
+		m.order = m.order[1:]
+	}
+	m.bins[b.ID] = &memoryBin{bin: b}
+	m.order = append(m.order, b.ID)
+	return nil
+}
+
+func (m *memoryBinStore) get(id string) (*bin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mb, ok := m.bins[id]
+	if !ok {
+		return nil, errBinNotFound
+	}
+	return mb.bin, nil
+}
+
+func (m *memoryBinStore) add(id string, req *capturedRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mb, ok := m.bins[id]
+	if !ok {
+		return errBinNotFound
+	}
+	if len(mb.requests) < m.capacity {
+		mb.requests = append(mb.requests, req)
+		return nil
+	}
+	mb.requests[mb.start] = req
+	mb.start = (mb.start + 1) % m.capacity
+	return nil
+}
+
+func (m *memoryBinStore) list(id string, offset, limit int) ([]*capturedRequest, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mb, ok := m.bins[id]
+	if !ok {
+		return nil, 0, errBinNotFound
+	}
+	total := len(mb.requests)
+	var page []*capturedRequest
+	for i := offset; i < total && len(page) < limit; i++ {
+		page = append(page, mb.requests[(mb.start+i)%total])
+	}
+	return page, total, nil
+}
+
+// fileBinStore writes each bin to <dir>/<id>.json and appends its captured
+// requests as JSON lines to <dir>/<id>.jsonl, so bins survive restarts
+type fileBinStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func (f *fileBinStore) binPath(id string) string {
+	return filepath.Join(f.dir, id+".json")
+}
+
+func (f *fileBinStore) requestsPath(id string) string {
+	return filepath.Join(f.dir, id+".jsonl")
+}
+
+func (f *fileBinStore) create(b *bin) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.binPath(b.ID), data, 0644)
+}
+
+func (f *fileBinStore) get(id string) (*bin, error) {
+	data, err := ioutil.ReadFile(f.binPath(id))
+	if os.IsNotExist(err) {
+		return nil, errBinNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := &bin{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("unable to parse bin %s: %v", id, err)
+	}
+	return b, nil
+}
+
+func (f *fileBinStore) add(id string, req *capturedRequest) error {
+	if _, err := f.get(id); err != nil {
+		return err
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out, err := os.OpenFile(f.requestsPath(id), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(line); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (f *fileBinStore) list(id string, offset, limit int) ([]*capturedRequest, int, error) {
+	if _, err := f.get(id); err != nil {
+		return nil, 0, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	in, err := os.Open(f.requestsPath(id))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer in.Close()
+
+	var page []*capturedRequest
+	total := 0
+	rd := bufio.NewReader(in)
+	for {
+		line, err := rd.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if total >= offset && len(page) < limit {
+				req := &capturedRequest{}
+				if err := json.Unmarshal(line, req); err != nil {
+					return nil, 0, fmt.Errorf("unable to parse request in bin %s: %v", id, err)
+				}
+				page = append(page, req)
+			}
+			total++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return page, total, nil
+}
@@ -0,0 +1,222 @@
+package httpbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxBinPageSize caps per_page when listing captured requests
+const maxBinPageSize = 100
+
+// bin captures the requests sent to /bins/{id}/...
+type bin struct {
+	ID       string      `json:"id"`
+	Created  time.Time   `json:"created"`
+	Response binResponse `json:"response"`
+}
+
+// binResponse is returned to every request captured by a bin
+type binResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// capturedRequest is the record of a request captured by a bin. It extends
+// Request with the raw request as received.
+type capturedRequest struct {
+	Request
+
+	ID         string       `json:"id"`
+	Received   time.Time    `json:"received"`
+	Path       string       `json:"path"`
+	Query      string       `json:"query"`
+	RawHeaders http.Header  `json:"raw_headers"`
+	Body       string       `json:"body"`
+	BodyReadMS float64      `json:"body_read_ms"`
+	RemoteAddr string       `json:"remote_addr"`
+	BodySize   int          `json:"body_size"`
+	TLS        *tlsResponse `json:"tls,omitempty"`
+}
+
+type binInfo struct {
+	*bin
+	URL         string `json:"url"`
+	RequestsURL string `json:"requests_url"`
+}
+
+type binRequestsResponse struct {
+	Bin      string             `json:"bin"`
+	Total    int                `json:"total"`
+	Page     int                `json:"page"`
+	PerPage  int                `json:"per_page"`
+	Requests []*capturedRequest `json:"requests"`
+}
+
+func (s *Server) handleCreateBin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b := &bin{
+			ID:       uuid.New().String(),
+			Created:  s.now().UTC(),
+			Response: binResponse{Status: http.StatusOK},
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &b.Response); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid bin response: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if b.Response.Status < 100 || b.Response.Status > 999 {
+			http.Error(w, "Invalid bin response status", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.bins.create(b); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		binURL := getBaseURL(r) + s.path("/bins/"+b.ID)
+		w.Header().Set("Location", binURL)
+		writeJSON(w, http.StatusCreated, binInfo{bin: b, URL: binURL, RequestsURL: binURL + "/requests"})
+	}
+}
+
+func (s *Server) handleBinCapture() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		b, err := s.getBin(id)
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+
+		received := s.now().UTC()
+		start := time.Now()
+		raw, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeRequestError(w, &bodyError{err})
+			return
+		}
+		bodyRead := time.Since(start)
+		r.Body = ioutil.NopCloser(bytes.NewReader(raw))
+
+		req, err := parseRequest(r)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+
+		captured := &capturedRequest{
+			Request:    *req,
+			ID:         uuid.New().String(),
+			Received:   received,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			RawHeaders: r.Header,
+			Body:       jsonSafe(raw, r.Header.Get("Content-Type")),
+			BodyReadMS: float64(bodyRead) / float64(time.Millisecond),
+			RemoteAddr: r.RemoteAddr,
+			BodySize:   len(raw),
+		}
+		if r.TLS != nil {
+			tlsResp := newTLSResponse(r.TLS)
+			captured.TLS = &tlsResp
+		}
+		if err := s.bins.add(id, captured); err != nil {
+			writeBinError(w, err)
+			return
+		}
+
+		for key, val := range b.Response.Headers {
+			w.Header().Set(key, val)
+		}
+		w.Header().Set("X-Bin-Request-Id", captured.ID)
+		w.WriteHeader(b.Response.Status)
+		w.Write([]byte(b.Response.Body))
+	}
+}
+
+func (s *Server) handleBinRequests() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if _, err := s.getBin(id); err != nil {
+			writeBinError(w, err)
+			return
+		}
+
+		query := r.URL.Query()
+		page, err := parsePageParam(query.Get("page"), 1)
+		if err != nil {
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+		perPage, err := parsePageParam(query.Get("per_page"), 20)
+		if err != nil {
+			http.Error(w, "Invalid per_page", http.StatusBadRequest)
+			return
+		}
+		if perPage > maxBinPageSize {
+			perPage = maxBinPageSize
+		}
+
+		requests, total, err := s.bins.list(id, (page-1)*perPage, perPage)
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+		if requests == nil {
+			requests = []*capturedRequest{}
+		}
+
+		writeJSON(w, http.StatusOK, binRequestsResponse{
+			Bin:      id,
+			Total:    total,
+			Page:     page,
+			PerPage:  perPage,
+			Requests: requests,
+		})
+	}
+}
+
+// getBin looks up a bin, rejecting IDs that are not UUIDs before they reach
+// the store
+func (s *Server) getBin(id string) (*bin, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errBinNotFound
+	}
+	return s.bins.get(id)
+}
+
+func parsePageParam(val string, def int) (int, error) {
+	if val == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid page parameter: %s", val)
+	}
+	return n, nil
+}
+
+func writeBinError(w http.ResponseWriter, err error) {
+	if err == errBinNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,127 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func createBin(t *testing.T, handler http.Handler, body string) binInfo {
+	w := serve(handler, "POST", "/bins", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got: %d %s", w.Code, w.Body)
+	}
+	info := binInfo{bin: &bin{}}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse bin. Err: %v", err)
+	}
+	return info
+}
+
+func listBin(t *testing.T, handler http.Handler, id, query string) binRequestsResponse {
+	w := serve(handler, "GET", "/bins/"+id+"/requests"+query, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d %s", w.Code, w.Body)
+	}
+	var resp binRequestsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse bin requests. Err: %v", err)
+	}
+	return resp
+}
+
+func TestBins_CaptureAndList(t *testing.T) {
+	for _, store := range []string{BinStoreMemory, BinStoreFile} {
+		config := &Config{BinStore: store}
+		if store == BinStoreFile {
+			dir, err := ioutil.TempDir("", "bins")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			config.BinDir = dir
+		}
+		handler := newTestServer(t, WithConfig(config)).Handler()
+
+		info := createBin(t, handler, `{"status": 202, "headers": {"X-Hook": "ok"}, "body": "accepted"}`)
+		if info.URL != "http://test.com/bins/"+info.ID || info.RequestsURL != info.URL+"/requests" {
+			t.Errorf("%s: unexpected bin URLs: %s %s", store, info.URL, info.RequestsURL)
+		}
+
+		r := httptest.NewRequest("POST", "http://test.com/bins/"+info.ID+"/hooks/github?event=push", strings.NewReader(`{"ref":"main"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Add("X-Multi", "a")
+		r.Header.Add("X-Multi", "b")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusAccepted || w.Body.String() != "accepted" || w.Header().Get("X-Hook") != "ok" {
+			t.Errorf("%s: expected configured response, got: %d %q", store, w.Code, w.Body)
+		}
+
+		serve(handler, "GET", "/bins/"+info.ID, "")
+
+		resp := listBin(t, handler, info.ID, "")
+		if resp.Total != 2 || len(resp.Requests) != 2 {
+			t.Fatalf("%s: expected 2 captured requests, got: %d", store, resp.Total)
+		}
+		captured := resp.Requests[0]
+		if captured.Method != "POST" || captured.Path != "/bins/"+info.ID+"/hooks/github" || captured.Query != "event=push" {
+			t.Errorf("%s: unexpected method, path or query: %s %s %s", store, captured.Method, captured.Path, captured.Query)
+		}
+		if captured.Body != `{"ref":"main"}` || captured.BodySize != 14 {
+			t.Errorf("%s: unexpected body: %q", store, captured.Body)
+		}
+		if vals := captured.RawHeaders["X-Multi"]; len(vals) != 2 {
+			t.Errorf("%s: expected both X-Multi headers, got: %v", store, vals)
+		}
+		if captured.ID != w.Header().Get("X-Bin-Request-Id") {
+			t.Errorf("%s: expected request ID %s, got: %s", store, w.Header().Get("X-Bin-Request-Id"), captured.ID)
+		}
+
+		resp = listBin(t, handler, info.ID, "?page=2&per_page=1")
+		if len(resp.Requests) != 1 || resp.Requests[0].Method != "GET" {
+			t.Errorf("%s: expected second page to hold the GET request", store)
+		}
+	}
+}
+
+func TestBins_MemoryRingBuffer(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{BinCapacity: 3})).Handler()
+	info := createBin(t, handler, "")
+
+	for _, arg := range []string{"1", "2", "3", "4", "5"} {
+		serve(handler, "GET", "/bins/"+info.ID+"?n="+arg, "")
+	}
+
+	resp := listBin(t, handler, info.ID, "")
+	if resp.Total != 3 {
+		t.Fatalf("Expected 3 captured requests, got: %d", resp.Total)
+	}
+	for i, expected := range []string{"n=3", "n=4", "n=5"} {
+		if query := resp.Requests[i].Query; query != expected {
+			t.Errorf("Expected request %d to be %s, got: %s", i, expected, query)
+		}
+	}
+}
+
+func TestBins_NotFound(t *testing.T) {
+	handler := newTestServer(t).Handler()
+	for _, target := range []string{"/bins/missing", "/bins/00000000-0000-0000-0000-000000000000/requests"} {
+		w := serve(handler, "GET", target, "")
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for %s, got: %d", target, w.Code)
+		}
+	}
+}
+
+func TestBins_InvalidResponse(t *testing.T) {
+	handler := newTestServer(t).Handler()
+	w := serve(handler, "POST", "/bins", `{"status": 42}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got: %d", w.Code)
+	}
+}
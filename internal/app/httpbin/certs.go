@@ -0,0 +1,173 @@
+package httpbin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Files written to Config.TLSDir when generating a self-signed CA
+const (
+	caCertFile     = "ca.pem"
+	serverCertFile = "server.pem"
+	serverKeyFile  = "server-key.pem"
+	clientCertFile = "client.pem"
+	clientKeyFile  = "client-key.pem"
+)
+
+// selfSignedCerts holds a generated CA along with a server and client
+// certificate signed by it
+type selfSignedCerts struct {
+	ca     *x509.Certificate
+	server tls.Certificate
+	client tls.Certificate
+}
+
+// generateSelfSignedCerts creates a throwaway CA, a server certificate valid
+// for hosts and a client certificate for testing mutual TLS
+func generateSelfSignedCerts(hosts []string) (*selfSignedCerts, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate, err := certTemplate("httpbin-go CA")
+	if err != nil {
+		return nil, err
+	}
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create CA certificate: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	serverTemplate, err := certTemplate("httpbin-go server")
+	if err != nil {
+		return nil, err
+	}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+	server, err := signedCert(serverTemplate, ca, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create server certificate: %v", err)
+	}
+
+	clientTemplate, err := certTemplate("httpbin-go client")
+	if err != nil {
+		return nil, err
+	}
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client, err := signedCert(clientTemplate, ca, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client certificate: %v", err)
+	}
+
+	return &selfSignedCerts{ca: ca, server: server, client: client}, nil
+}
+
+func certTemplate(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"httpbin-go"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, nil
+}
+
+func signedCert(template, ca *x509.Certificate, caKey crypto.Signer) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der, ca.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// writeFiles writes the CA, server and client certificates and keys as PEM
+// files to dir
+func (c *selfSignedCerts) writeFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if err := writePEM(filepath.Join(dir, caCertFile), 0644, pemCert(c.ca.Raw)...); err != nil {
+		return err
+	}
+	pairs := []struct {
+		cert    tls.Certificate
+		certOut string
+		keyOut  string
+	}{
+		{c.server, serverCertFile, serverKeyFile},
+		{c.client, clientCertFile, clientKeyFile},
+	}
+	for _, pair := range pairs {
+		keyDER, err := x509.MarshalPKCS8PrivateKey(pair.cert.PrivateKey)
+		if err != nil {
+			return err
+		}
+		if err := writePEM(filepath.Join(dir, pair.certOut), 0644, pemCert(pair.cert.Certificate...)...); err != nil {
+			return err
+		}
+		if err := writePEM(filepath.Join(dir, pair.keyOut), 0600, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pemCert(ders ...[]byte) []*pem.Block {
+	blocks := make([]*pem.Block, len(ders))
+	for i, der := range ders {
+		blocks[i] = &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	}
+	return blocks
+}
+
+func writePEM(path string, perm os.FileMode, blocks ...*pem.Block) error {
+	var out []byte
+	for _, block := range blocks {
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
+	return ioutil.WriteFile(path, out, perm)
+}
@@ -0,0 +1,496 @@
+package httpbin
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default configuration values
+const (
+	DefaultAddr     = "0.0.0.0:8080"
+	DefaultMaxBytes = 100 * 1024
+	DefaultMaxDelay = 10 * time.Second
+
+	DefaultDrainTimeout = 30 * time.Second
+	DefaultBinCapacity  = 100
+)
+
+// Config holds the server configuration. Settings are resolved from, in
+// increasing order of precedence: defaults, an optional JSON config file,
+// HTTPBIN_* environment variables and command line flags.
+type Config struct {
+	// Addr is the TCP address the server listens on
+	Addr string `json:"addr"`
+
+	// BasePath mounts all routes under a path prefix, e.g. "/httpbin", and
+	// is included in every URL the server generates
+	BasePath string `json:"base_path"`
+
+	// ReadTimeout, WriteTimeout and IdleTimeout are passed to http.Server,
+	// zero means no timeout
+	ReadTimeout  Duration `json:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout"`
+	IdleTimeout  Duration `json:"idle_timeout"`
+
+	// ShutdownDelay is how long the server keeps serving after a SIGTERM
+	// with /readyz reporting not ready, giving load balancers time to stop
+	// routing to it. DrainTimeout then bounds how long in-flight requests
+	// get to complete.
+	ShutdownDelay Duration `json:"shutdown_delay"`
+	DrainTimeout  Duration `json:"drain_timeout"`
+
+	// MaxBodySize caps the size of request bodies in bytes, zero means no
+	// limit
+	MaxBodySize int64 `json:"max_body_size"`
+
+	// MaxBytes caps the generated payload of /bytes, /stream-bytes and
+	// /range
+	MaxBytes int `json:"max_bytes"`
+
+	// MaxDelay caps the delays of /delay, /drip and /range
+	MaxDelay Duration `json:"max_delay"`
+
+	// TemplatesDir and ImagesDir hold custom assets that override the
+	// embedded templates and images of the same name
+	TemplatesDir string `json:"templates_dir"`
+	ImagesDir    string `json:"images_dir"`
+
+	// ResponseTemplatesDir holds *.tmpl response templates served at
+	// /template/{name}, where name is the file name without .tmpl
+	ResponseTemplatesDir string `json:"response_templates_dir"`
+
+	// AdhocTemplates lets /template render the template in its tmpl query
+	// parameter, running templates supplied by any client
+	AdhocTemplates bool `json:"adhoc_templates"`
+
+	// RouteGroups lists the enabled route groups, empty enables all
+	RouteGroups []string `json:"route_groups"`
+
+	// MultiValue emits repeated args, form fields and headers as arrays
+	MultiValue bool `json:"multi_value"`
+
+	// AccessLog is the access log format: none, common, combined or json.
+	// Entries are appended to AccessLogFile, "-" or empty for stdout.
+	AccessLog     string `json:"access_log"`
+	AccessLogFile string `json:"access_log_file"`
+
+	// BinStore selects where bins and their captured requests are kept:
+	// memory, holding the last BinCapacity requests of each bin, or file,
+	// appending them as JSON lines to files in BinDir
+	BinStore    string `json:"bin_store"`
+	BinCapacity int    `json:"bin_capacity"`
+	BinDir      string `json:"bin_dir"`
+
+	// FaultInjection applies the faults requested by the X-Httpbin-Fault
+	// header or _fault query parameter to any route
+	FaultInjection bool `json:"fault_injection"`
+
+	// Admin enables the admin API under /_admin, which registers mock
+	// endpoints at runtime
+	Admin bool `json:"admin"`
+
+	// JWTSecret verifies and signs HS256/384/512 tokens. RS, ES and EdDSA
+	// tokens use the keys in JWKSFile, or keys generated at startup.
+	JWTSecret string `json:"jwt_secret"`
+	JWKSFile  string `json:"jwks_file"`
+
+	// JWTIssuer and JWTAudience, when set, are required of the iss and aud
+	// claims of tokens. JWTLeeway is the clock skew allowed when checking
+	// exp, nbf and iat.
+	JWTIssuer   string   `json:"jwt_issuer"`
+	JWTAudience string   `json:"jwt_audience"`
+	JWTLeeway   Duration `json:"jwt_leeway"`
+
+	// OAuthUsers and OAuthClients are the users and clients of the mock
+	// OAuth 2.0 / OpenID Connect provider, only set in the config file.
+	// Without them a default user and clients are used.
+	OAuthUsers   []OAuthUser   `json:"oauth_users"`
+	OAuthClients []OAuthClient `json:"oauth_clients"`
+
+	// HTTP2 enables HTTP/2 over TLS and cleartext HTTP/2 (h2c), both with
+	// prior knowledge and through Upgrade: h2c
+	HTTP2 bool `json:"http2"`
+
+	// TLSCertFile and TLSKeyFile enable HTTPS using the given PEM files
+	TLSCertFile string `json:"tls_cert"`
+	TLSKeyFile  string `json:"tls_key"`
+
+	// TLSSelfSigned enables HTTPS using a CA, server and client certificate
+	// generated at startup and written to TLSDir. The server certificate is
+	// valid for TLSHosts.
+	TLSSelfSigned bool     `json:"tls_self_signed"`
+	TLSDir        string   `json:"tls_dir"`
+	TLSHosts      []string `json:"tls_hosts"`
+
+	// TLSClientCAFile is a PEM bundle used to verify client certificates.
+	// TLSClientAuth is one of none, request, require, verify-if-given or
+	// require-and-verify and defaults to verify-if-given when a client CA
+	// is available, otherwise request.
+	TLSClientCAFile string `json:"tls_client_ca"`
+	TLSClientAuth   string `json:"tls_client_auth"`
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() *Config {
+	return &Config{
+		Addr:         DefaultAddr,
+		AccessLog:    AccessLogCommon,
+		DrainTimeout: Duration(DefaultDrainTimeout),
+		MaxBytes:     DefaultMaxBytes,
+		MaxDelay:     Duration(DefaultMaxDelay),
+		BinStore:     BinStoreMemory,
+		BinCapacity:  DefaultBinCapacity,
+		BinDir:       "bins",
+		HTTP2:        true,
+		TLSDir:       "certs",
+		TLSHosts:     []string{"localhost", "127.0.0.1", "::1"},
+	}
+}
+
+// Duration is a time.Duration that is read from config files as a string
+// such as "1m30s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string, or a number of seconds
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var secs float64
+		if err := json.Unmarshal(b, &secs); err != nil {
+			return fmt.Errorf("invalid duration %s", b)
+		}
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type setting struct {
+	name   string
+	usage  string
+	isBool bool
+	set    func(c *Config, val string) error
+}
+
+var settings = []setting{
+	{name: "addr", usage: "address to listen on", set: func(c *Config, val string) error {
+		c.Addr = val
+		return nil
+	}},
+	{name: "base-path", usage: "path prefix all routes are mounted under", set: func(c *Config, val string) error {
+		c.BasePath = val
+		return nil
+	}},
+	{name: "read-timeout", usage: "maximum duration for reading a request", set: func(c *Config, val string) error {
+		return setDuration(&c.ReadTimeout, val)
+	}},
+	{name: "write-timeout", usage: "maximum duration for writing a response", set: func(c *Config, val string) error {
+		return setDuration(&c.WriteTimeout, val)
+	}},
+	{name: "idle-timeout", usage: "maximum duration to keep idle connections open", set: func(c *Config, val string) error {
+		return setDuration(&c.IdleTimeout, val)
+	}},
+	{name: "shutdown-delay", usage: "how long to keep serving with /readyz failing after SIGTERM", set: func(c *Config, val string) error {
+		return setDuration(&c.ShutdownDelay, val)
+	}},
+	{name: "drain-timeout", usage: "maximum duration to wait for in-flight requests on shutdown", set: func(c *Config, val string) error {
+		return setDuration(&c.DrainTimeout, val)
+	}},
+	{name: "max-body-size", usage: "maximum request body size in bytes, 0 for no limit", set: func(c *Config, val string) error {
+		size, err := strconv.ParseInt(val, 10, 64)
+		c.MaxBodySize = size
+		return err
+	}},
+	{name: "max-bytes", usage: "maximum payload size of /bytes, /stream-bytes and /range", set: func(c *Config, val string) error {
+		n, err := strconv.Atoi(val)
+		c.MaxBytes = n
+		return err
+	}},
+	{name: "max-delay", usage: "maximum delay of /delay, /drip and /range", set: func(c *Config, val string) error {
+		return setDuration(&c.MaxDelay, val)
+	}},
+	{name: "templates-dir", usage: "directory of templates overriding the embedded ones", set: func(c *Config, val string) error {
+		c.TemplatesDir = val
+		return nil
+	}},
+	{name: "images-dir", usage: "directory of images overriding the embedded ones", set: func(c *Config, val string) error {
+		c.ImagesDir = val
+		return nil
+	}},
+	{name: "response-templates-dir", usage: "directory of *.tmpl response templates served at /template/{name}", set: func(c *Config, val string) error {
+		c.ResponseTemplatesDir = val
+		return nil
+	}},
+	{name: "adhoc-templates", usage: "let /template render the template in its tmpl parameter", isBool: true, set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		c.AdhocTemplates = b
+		return err
+	}},
+	{name: "route-groups", usage: "comma separated list of enabled route groups, empty for all", set: func(c *Config, val string) error {
+		c.RouteGroups = splitList(val)
+		return nil
+	}},
+	{name: "multi-value", usage: "emit repeated args, form fields and headers as arrays", isBool: true, set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		c.MultiValue = b
+		return err
+	}},
+	{name: "access-log", usage: "access log format: none, common, combined or json", set: func(c *Config, val string) error {
+		c.AccessLog = val
+		return nil
+	}},
+	{name: "access-log-file", usage: "file access logs are appended to, - for stdout", set: func(c *Config, val string) error {
+		c.AccessLogFile = val
+		return nil
+	}},
+	{name: "bin-store", usage: "storage for request bins: memory or file", set: func(c *Config, val string) error {
+		c.BinStore = val
+		return nil
+	}},
+	{name: "bin-capacity", usage: "requests kept per bin by the memory store", set: func(c *Config, val string) error {
+		n, err := strconv.Atoi(val)
+		c.BinCapacity = n
+		return err
+	}},
+	{name: "bin-dir", usage: "directory the file bin store writes to", set: func(c *Config, val string) error {
+		c.BinDir = val
+		return nil
+	}},
+	{name: "fault-injection", usage: "apply faults requested by the X-Httpbin-Fault header or _fault parameter", isBool: true, set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		c.FaultInjection = b
+		return err
+	}},
+	{name: "admin", usage: "enable the admin API for registering mock endpoints", isBool: true, set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		c.Admin = b
+		return err
+	}},
+	{name: "jwt-secret", usage: "secret for HS256/384/512 tokens", set: func(c *Config, val string) error {
+		c.JWTSecret = val
+		return nil
+	}},
+	{name: "jwks-file", usage: "JWKS file of keys for RS, ES and EdDSA tokens, generated when empty", set: func(c *Config, val string) error {
+		c.JWKSFile = val
+		return nil
+	}},
+	{name: "jwt-issuer", usage: "iss claim required of tokens", set: func(c *Config, val string) error {
+		c.JWTIssuer = val
+		return nil
+	}},
+	{name: "jwt-audience", usage: "aud claim required of tokens", set: func(c *Config, val string) error {
+		c.JWTAudience = val
+		return nil
+	}},
+	{name: "jwt-leeway", usage: "clock skew allowed when checking token exp, nbf and iat", set: func(c *Config, val string) error {
+		return setDuration(&c.JWTLeeway, val)
+	}},
+	{name: "http2", usage: "serve HTTP/2 over TLS and cleartext h2c", isBool: true, set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		c.HTTP2 = b
+		return err
+	}},
+	{name: "tls-cert", usage: "PEM certificate file, enables HTTPS", set: func(c *Config, val string) error {
+		c.TLSCertFile = val
+		return nil
+	}},
+	{name: "tls-key", usage: "PEM private key file for -tls-cert", set: func(c *Config, val string) error {
+		c.TLSKeyFile = val
+		return nil
+	}},
+	{name: "tls-self-signed", usage: "serve HTTPS using a generated self-signed CA", isBool: true, set: func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		c.TLSSelfSigned = b
+		return err
+	}},
+	{name: "tls-dir", usage: "directory the generated certificates are written to", set: func(c *Config, val string) error {
+		c.TLSDir = val
+		return nil
+	}},
+	{name: "tls-hosts", usage: "comma separated hosts the generated server certificate is valid for", set: func(c *Config, val string) error {
+		c.TLSHosts = splitList(val)
+		return nil
+	}},
+	{name: "tls-client-ca", usage: "PEM bundle used to verify client certificates", set: func(c *Config, val string) error {
+		c.TLSClientCAFile = val
+		return nil
+	}},
+	{name: "tls-client-auth", usage: "client certificate policy: none, request, require, verify-if-given or require-and-verify", set: func(c *Config, val string) error {
+		c.TLSClientAuth = val
+		return nil
+	}},
+}
+
+type settingValue struct {
+	isBool bool
+	val    string
+}
+
+func (v *settingValue) String() string   { return v.val }
+func (v *settingValue) IsBoolFlag() bool { return v.isBool }
+func (v *settingValue) Set(val string) error {
+	v.val = val
+	return nil
+}
+
+// LoadConfig resolves the configuration from the command line arguments,
+// the environment and the config file named by -config or HTTPBIN_CONFIG
+func LoadConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("httpbin-go", flag.ExitOnError)
+	return loadConfig(fs, args, os.LookupEnv)
+}
+
+func loadConfig(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	configFile := fs.String("config", "", "path to a JSON or YAML (.yaml, .yml) config file (env: HTTPBIN_CONFIG)")
+	values := make(map[string]*settingValue, len(settings))
+	for _, s := range settings {
+		v := &settingValue{isBool: s.isBool}
+		values[s.name] = v
+		fs.Var(v, s.name, fmt.Sprintf("%s (env: %s)", s.usage, envName(s.name)))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultConfig()
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(envName("config"))
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if val, ok := lookupEnv(envName(s.name)); ok {
+			if err := s.set(cfg, val); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", envName(s.name), err)
+			}
+		}
+	}
+
+	visited := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { visited[f.Name] = true })
+	for _, s := range settings {
+		if visited[s.name] {
+			if err := s.set(cfg, values[s.name].val); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %v", s.name, err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to open config file: %v", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("unable to parse config file %s: %v", path, err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("unable to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// yamlToJSON converts a YAML config file to JSON, so both are decoded with
+// the same keys and duration formats
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// clone returns a copy of the config that shares no slices or maps with
+// it, so the caller can keep changing its own
+func (c *Config) clone() *Config {
+	copied := *c
+	copied.RouteGroups = append([]string(nil), c.RouteGroups...)
+	copied.TLSHosts = append([]string(nil), c.TLSHosts...)
+	if c.OAuthUsers != nil {
+		copied.OAuthUsers = make([]OAuthUser, len(c.OAuthUsers))
+		for i, u := range c.OAuthUsers {
+			if u.Claims != nil {
+				claims := make(map[string]interface{}, len(u.Claims))
+				for k, v := range u.Claims {
+					claims[k] = v
+				}
+				u.Claims = claims
+			}
+			copied.OAuthUsers[i] = u
+		}
+	}
+	if c.OAuthClients != nil {
+		copied.OAuthClients = make([]OAuthClient, len(c.OAuthClients))
+		for i, client := range c.OAuthClients {
+			client.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+			client.Scopes = append([]string(nil), client.Scopes...)
+			copied.OAuthClients[i] = client
+		}
+	}
+	return &copied
+}
+
+func (c *Config) tlsEnabled() bool {
+	return c.TLSSelfSigned || c.TLSCertFile != ""
+}
+
+func (c *Config) routeGroupEnabled(name string) bool {
+	if len(c.RouteGroups) == 0 {
+		return true
+	}
+	return stringInSlice(name, c.RouteGroups)
+}
+
+func envName(name string) string {
+	return "HTTPBIN_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+func setDuration(d *Duration, val string) error {
+	parsed, err := time.ParseDuration(val)
+	*d = Duration(parsed)
+	return err
+}
+
+func splitList(val string) []string {
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
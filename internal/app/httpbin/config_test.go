@@ -0,0 +1,187 @@
+package httpbin
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func lookupEnvMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, nil, lookupEnvMap(nil))
+	if err != nil {
+		t.Fatalf("Failed to load config. Err: %v", err)
+	}
+
+	if cfg.Addr != DefaultAddr {
+		t.Errorf("got %s, want %s", cfg.Addr, DefaultAddr)
+	}
+	if cfg.MaxBytes != DefaultMaxBytes {
+		t.Errorf("got %d, want %d", cfg.MaxBytes, DefaultMaxBytes)
+	}
+	if time.Duration(cfg.MaxDelay) != DefaultMaxDelay {
+		t.Errorf("got %v, want %v", time.Duration(cfg.MaxDelay), DefaultMaxDelay)
+	}
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpbin-config")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	contents := `{"addr": "127.0.0.1:9000", "max_bytes": 10, "max_delay": "2s", "read_timeout": 5, "route_groups": ["http"]}`
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write config file. Err: %v", err)
+	}
+
+	env := map[string]string{
+		"HTTPBIN_CONFIG":    path,
+		"HTTPBIN_MAX_BYTES": "20",
+		"HTTPBIN_ADDR":      "127.0.0.1:9001",
+	}
+	args := []string{"-addr", "127.0.0.1:9002", "-multi-value"}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args, lookupEnvMap(env))
+	if err != nil {
+		t.Fatalf("Failed to load config. Err: %v", err)
+	}
+
+	if cfg.Addr != "127.0.0.1:9002" {
+		t.Errorf("flag should take precedence, got %s", cfg.Addr)
+	}
+	if cfg.MaxBytes != 20 {
+		t.Errorf("env should take precedence over file, got %d", cfg.MaxBytes)
+	}
+	if time.Duration(cfg.MaxDelay) != 2*time.Second {
+		t.Errorf("file should take precedence over defaults, got %v", time.Duration(cfg.MaxDelay))
+	}
+	if time.Duration(cfg.ReadTimeout) != 5*time.Second {
+		t.Errorf("numeric durations should be seconds, got %v", time.Duration(cfg.ReadTimeout))
+	}
+	if !cfg.MultiValue {
+		t.Errorf("Expected -multi-value to enable multi value output")
+	}
+	if len(cfg.RouteGroups) != 1 || cfg.RouteGroups[0] != "http" {
+		t.Errorf("got %v, want [http]", cfg.RouteGroups)
+	}
+}
+
+func TestLoadConfig_YAML(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpbin-config")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	contents := `
+addr: 127.0.0.1:9000
+max_delay: 2s
+read_timeout: 5
+route_groups: [http]
+oauth_clients:
+  - client_id: app
+    scopes: [read]
+    access_token_ttl: 1m
+`
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write config file. Err: %v", err)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, []string{"-config", path}, lookupEnvMap(nil))
+	if err != nil {
+		t.Fatalf("Failed to load config. Err: %v", err)
+	}
+	if cfg.Addr != "127.0.0.1:9000" || time.Duration(cfg.MaxDelay) != 2*time.Second || time.Duration(cfg.ReadTimeout) != 5*time.Second {
+		t.Errorf("Unexpected config from YAML: %+v", cfg)
+	}
+	if len(cfg.RouteGroups) != 1 || len(cfg.OAuthClients) != 1 || cfg.OAuthClients[0].ID != "app" || time.Duration(cfg.OAuthClients[0].AccessTokenTTL) != time.Minute {
+		t.Errorf("Unexpected lists from YAML: %v %+v", cfg.RouteGroups, cfg.OAuthClients)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("unknown_key: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file. Err: %v", err)
+	}
+	if _, err := loadConfig(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-config", path}, lookupEnvMap(nil)); err == nil {
+		t.Errorf("Expected unknown keys in a YAML config to be rejected")
+	}
+}
+
+func TestLoadConfig_InvalidEnv(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	env := map[string]string{"HTTPBIN_MAX_DELAY": "forever"}
+	if _, err := loadConfig(fs, nil, lookupEnvMap(env)); err == nil {
+		t.Errorf("Expected an error for an invalid duration")
+	}
+}
+
+func TestNewServer_RouteGroups(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RouteGroups = []string{"http"}
+
+	router := mux.NewRouter()
+	if _, err := NewServer(router, WithConfig(cfg)); err != nil {
+		t.Fatalf("Failed to build server. Err: %v", err)
+	}
+
+	var match mux.RouteMatch
+	req := newTestRequest(nil, "http://test.com/get", "GET")
+	if !router.Match(req.baseRequest, &match) {
+		t.Errorf("Expected /get to be routed")
+	}
+	req = newTestRequest(nil, "http://test.com/uuid", "GET")
+	if router.Match(req.baseRequest, &match) {
+		t.Errorf("Expected /uuid to be disabled")
+	}
+
+	cfg.RouteGroups = []string{"nope"}
+	if _, err := NewServer(mux.NewRouter(), WithConfig(cfg)); err == nil {
+		t.Errorf("Expected an error for an unknown route group")
+	}
+}
+
+func TestNewServer_OptionOrder(t *testing.T) {
+	config := &Config{}
+	server := newTestServer(t, WithMultiValue(), WithPathPrefix("/httpbin"), WithConfig(config))
+
+	if cfg := server.cfg(); !cfg.MultiValue || cfg.BasePath != "/httpbin" {
+		t.Errorf("Expected options before WithConfig to apply, got MultiValue %v, BasePath %q", cfg.MultiValue, cfg.BasePath)
+	}
+	if config.MultiValue || config.BasePath != "" {
+		t.Errorf("Expected the caller's config to be left untouched, got %+v", config)
+	}
+}
+
+func TestWithConfig_Copies(t *testing.T) {
+	config := &Config{
+		RouteGroups:  []string{"http"},
+		OAuthUsers:   []OAuthUser{{Username: "ann", Claims: map[string]interface{}{"role": "admin"}}},
+		OAuthClients: []OAuthClient{{ID: "app", Scopes: []string{"read"}}},
+	}
+	server := newTestServer(t, WithConfig(config))
+
+	config.RouteGroups[0] = "auth"
+	config.OAuthUsers[0].Claims["role"] = "guest"
+	config.OAuthClients[0].Scopes[0] = "write"
+	cfg := server.cfg()
+	if cfg.RouteGroups[0] != "http" || cfg.OAuthUsers[0].Claims["role"] != "admin" || cfg.OAuthClients[0].Scopes[0] != "read" {
+		t.Errorf("Expected later changes to the caller's config not to reach the server, got %+v", cfg)
+	}
+}
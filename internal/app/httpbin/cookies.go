@@ -54,14 +54,14 @@ func (s *Server) handleCookiesDelete() http.HandlerFunc {
 			if stringInSlice(cookie.Name, toDelete) {
 				c := http.Cookie{
 					Name:    cookie.Name,
-					Path:    "/",
-					Expires: time.Now().Add(-100 * time.Hour),
+					Path:    s.path("/"),
+					Expires: s.now().Add(-100 * time.Hour),
 					MaxAge:  -1,
 				}
 				http.SetCookie(w, &c)
 			}
 		}
-		http.Redirect(w, r, "/cookies", http.StatusFound)
+		http.Redirect(w, r, s.path("/cookies"), http.StatusFound)
 	}
 }
 
@@ -71,9 +71,9 @@ func (s *Server) handleCookiesSet() http.HandlerFunc {
 		if vars["name"] != "" && vars["value"] != "" {
 			c := http.Cookie{
 				Name:    vars["name"],
-				Path:    "/",
+				Path:    s.path("/"),
 				Value:   vars["value"],
-				Expires: time.Now().Add(3200 * time.Second),
+				Expires: s.now().Add(3200 * time.Second),
 				MaxAge:  3200,
 			}
 			http.SetCookie(w, &c)
@@ -83,15 +83,15 @@ func (s *Server) handleCookiesSet() http.HandlerFunc {
 		for k, v := range r.URL.Query() {
 			c := http.Cookie{
 				Name:    k,
-				Path:    "/",
+				Path:    s.path("/"),
 				Value:   strings.Join(v, ","),
-				Expires: time.Now().Add(3200 * time.Second),
+				Expires: s.now().Add(3200 * time.Second),
 				MaxAge:  3200,
 			}
 			http.SetCookie(w, &c)
 		}
 
-		http.Redirect(w, r, "/cookies", http.StatusFound)
+		http.Redirect(w, r, s.path("/cookies"), http.StatusFound)
 	}
 }
 
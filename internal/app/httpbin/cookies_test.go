@@ -2,6 +2,7 @@ package httpbin
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -71,3 +72,23 @@ func TestHandleCookiesSet_URLParams(t *testing.T) {
 		t.Errorf("Failed request base validations. Failure: %v", err)
 	}
 }
+
+func TestHandleCookiesSet_BasePath(t *testing.T) {
+	server := &Server{config: &Config{BasePath: "httpbin"}}
+	target := "http://test.com/httpbin/cookies/set/test/val"
+	req := newTestRequest(server.handleCookiesSet(), target, "GET", testReqStatus([]int{302}))
+	req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"name": "test", "value": "val"})
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.response.Header().Get("Location"); val != "/httpbin/cookies" {
+		t.Errorf("Expected Location to be /httpbin/cookies, got: %s", val)
+	}
+	if val := req.response.Header().Get("Set-Cookie"); !strings.Contains(val, "Path=/httpbin/") {
+		t.Errorf("Expected cookie to be scoped to the base path, got: %s", val)
+	}
+}
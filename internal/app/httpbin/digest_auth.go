@@ -0,0 +1,251 @@
+package httpbin
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+const digestRealm = "Fake Realm"
+
+// maxDigestNonces caps the number of outstanding digest nonces, the oldest
+// is forgotten once it is exceeded
+const maxDigestNonces = 10000
+
+// digestAlgorithms are the supported hash functions by name, each can also
+// be used in its -sess variant
+var digestAlgorithms = map[string]func() hash.Hash{
+	"MD5":         md5.New,
+	"SHA-256":     sha256.New,
+	"SHA-512":     sha512.New,
+	"SHA-512-256": sha512.New512_256,
+}
+
+// digestNonce is a nonce handed out in a challenge
+type digestNonce struct {
+	opaque string
+	// nc is the highest nonce count seen, uses the number of successful
+	// authentications
+	nc   uint64
+	uses int
+}
+
+// digestNonces tracks the nonces handed out in digest challenges so that
+// replayed and stale nonces are rejected
+type digestNonces struct {
+	mu     sync.Mutex
+	nonces map[string]*digestNonce
+	order  []string
+}
+
+func (d *digestNonces) issue() (nonce, opaque string) {
+	nonce, opaque = randomHex(16), randomHex(16)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.nonces == nil {
+		d.nonces = make(map[string]*digestNonce)
+	}
+	if len(d.order) >= maxDigestNonces {
+		delete(d.nonces, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.nonces[nonce] = &digestNonce{opaque: opaque}
+	d.order = append(d.order, nonce)
+	return nonce, opaque
+}
+
+// use records an authentication with nonce and nonce count nc. It reports
+// whether the nonce is unknown, replayed or used more than staleAfter
+// times, zero meaning never, in which case the client should retry with
+// a fresh nonce.
+func (d *digestNonces) use(nonce, opaque string, nc uint64, staleAfter int) (stale bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n, ok := d.nonces[nonce]
+	if !ok || n.opaque != opaque || nc <= n.nc {
+		return true
+	}
+	if staleAfter > 0 && n.uses >= staleAfter {
+		return true
+	}
+	n.nc = nc
+	n.uses++
+	return false
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleDigestAuth challenges with Digest authentication for the user and
+// password in the path, using the given qop, algorithm and the number of
+// uses after which a nonce goes stale. With ?require-cookie=true the fake
+// session cookie set by the challenge must also be sent back.
+func (s *Server) handleDigestAuth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		qop := vars["qop"]
+		if qop != "auth" && qop != "auth-int" {
+			http.Error(w, "Invalid qop, expected auth or auth-int", http.StatusBadRequest)
+			return
+		}
+		algorithm := strings.ToUpper(vars["algorithm"])
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		newHash, ok := digestAlgorithms[strings.TrimSuffix(algorithm, "-SESS")]
+		if !ok {
+			http.Error(w, "Invalid algorithm", http.StatusBadRequest)
+			return
+		}
+		staleAfter := 0
+		if val := vars["stale_after"]; val != "" && val != "never" {
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				http.Error(w, "Invalid stale_after", http.StatusBadRequest)
+				return
+			}
+			staleAfter = n
+		}
+
+		requireCookie := false
+		switch strings.ToLower(r.URL.Query().Get("require-cookie")) {
+		case "1", "t", "true":
+			requireCookie = true
+		}
+		if requireCookie {
+			if c, err := r.Cookie("fake"); err != nil || c.Value != "fake_value" {
+				s.digestChallenge(w, qop, algorithm, false)
+				return
+			}
+		}
+
+		creds, ok := parseDigestAuth(r.Header.Get("Authorization"))
+		if !ok || creds["username"] != vars["user"] || creds["realm"] != digestRealm ||
+			creds["uri"] != r.URL.RequestURI() || creds["qop"] != qop {
+			s.digestChallenge(w, qop, algorithm, false)
+			return
+		}
+		// clients may leave out the algorithm when it is MD5
+		if alg := creds["algorithm"]; !strings.EqualFold(alg, algorithm) && !(alg == "" && algorithm == "MD5") {
+			s.digestChallenge(w, qop, algorithm, false)
+			return
+		}
+		nc, err := strconv.ParseUint(creds["nc"], 16, 64)
+		if err != nil {
+			s.digestChallenge(w, qop, algorithm, false)
+			return
+		}
+
+		var body []byte
+		if qop == "auth-int" && r.Body != nil {
+			if body, err = ioutil.ReadAll(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		expected := digestResponse(newHash, algorithm, creds, vars["password"], r.Method, body)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(creds["response"]))) != 1 {
+			s.digestChallenge(w, qop, algorithm, false)
+			return
+		}
+		if s.digestNonces.use(creds["nonce"], creds["opaque"], nc, staleAfter) {
+			s.digestChallenge(w, qop, algorithm, true)
+			return
+		}
+
+		writeAuthenticated(w, vars["user"])
+	}
+}
+
+func (s *Server) digestChallenge(w http.ResponseWriter, qop, algorithm string, stale bool) {
+	nonce, opaque := s.digestNonces.issue()
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+		`Digest realm="%s", qop="%s", nonce="%s", opaque="%s", algorithm=%s, stale=%s`,
+		digestRealm, qop, nonce, opaque, algorithm, strings.ToUpper(strconv.FormatBool(stale))))
+	http.SetCookie(w, &http.Cookie{Name: "fake", Value: "fake_value", Path: s.path("/")})
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte("Unauthorized.\n"))
+}
+
+// digestResponse computes the expected response of RFC 7616
+func digestResponse(newHash func() hash.Hash, algorithm string, creds map[string]string, password, method string, body []byte) string {
+	h := func(parts ...string) string {
+		sum := newHash()
+		sum.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	ha1 := h(creds["username"], creds["realm"], password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1, creds["nonce"], creds["cnonce"])
+	}
+	ha2 := h(method, creds["uri"])
+	if creds["qop"] == "auth-int" {
+		ha2 = h(method, creds["uri"], h(string(body)))
+	}
+	return h(ha1, creds["nonce"], creds["nc"], creds["cnonce"], creds["qop"], ha2)
+}
+
+// parseDigestAuth parses the comma separated key=value pairs of a Digest
+// Authorization header, values may be quoted
+func parseDigestAuth(header string) (map[string]string, bool) {
+	const prefix = "Digest "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	rest := header[len(prefix):]
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" {
+			break
+		}
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, false
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimLeft(rest[eq+1:], " ")
+
+		var val strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				val.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return nil, false
+			}
+			rest = rest[i+1:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			val.WriteString(strings.TrimSpace(rest[:end]))
+			rest = rest[end:]
+		}
+		params[key] = val.String()
+	}
+	return params, true
+}
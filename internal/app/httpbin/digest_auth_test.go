@@ -0,0 +1,158 @@
+package httpbin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// digestClient answers digest challenges the way an HTTP client would
+type digestClient struct {
+	t       *testing.T
+	handler http.Handler
+	user    string
+	pass    string
+	params  map[string]string
+	nc      int
+	cookies []*http.Cookie
+}
+
+func (c *digestClient) do(method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for _, cookie := range c.cookies {
+		r.AddCookie(cookie)
+	}
+	if c.params != nil {
+		c.nc++
+		creds := map[string]string{
+			"username": c.user,
+			"realm":    c.params["realm"],
+			"nonce":    c.params["nonce"],
+			"opaque":   c.params["opaque"],
+			"uri":      r.URL.RequestURI(),
+			"qop":      c.params["qop"],
+			"nc":       fmt.Sprintf("%08x", c.nc),
+			"cnonce":   "0a4f113b",
+		}
+		algorithm := c.params["algorithm"]
+		newHash := digestAlgorithms[strings.TrimSuffix(algorithm, "-SESS")]
+		response := digestResponse(newHash, algorithm, creds, c.pass, method, []byte(body))
+		r.Header.Set("Authorization", fmt.Sprintf(
+			`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, qop=%s, nc=%s, cnonce="%s", response="%s", opaque="%s"`,
+			creds["username"], creds["realm"], creds["nonce"], creds["uri"], algorithm, creds["qop"], creds["nc"], creds["cnonce"], response, creds["opaque"]))
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+
+	if challenge := w.Header().Get("WWW-Authenticate"); challenge != "" {
+		params, ok := parseDigestAuth(challenge)
+		if !ok {
+			c.t.Fatalf("Failed to parse challenge: %s", challenge)
+		}
+		c.params = params
+		c.nc = 0
+		c.cookies = w.Result().Cookies()
+	}
+	return w
+}
+
+func TestHandleDigestAuth(t *testing.T) {
+	handler := newTestServer(t).Handler()
+	testCases := []struct {
+		path   string
+		method string
+		body   string
+	}{
+		{"/digest-auth/auth/user/passwd", "GET", ""},
+		{"/digest-auth/auth/user/passwd/MD5-sess", "GET", ""},
+		{"/digest-auth/auth/user/passwd/SHA-256", "GET", ""},
+		{"/digest-auth/auth-int/user/passwd/SHA-512-256", "POST", "payload"},
+		{"/digest-auth/auth-int/user/passwd/SHA-256-sess/never?a=1", "PUT", "payload"},
+	}
+	for _, tc := range testCases {
+		client := &digestClient{t: t, handler: handler, user: "user", pass: "passwd"}
+		if w := client.do(tc.method, "http://test.com"+tc.path, tc.body); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected a challenge for %s, got: %d", tc.path, w.Code)
+		}
+		if w := client.do(tc.method, "http://test.com"+tc.path, tc.body); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"authenticated": true`) {
+			t.Errorf("Expected %s to authenticate, got: %d %s", tc.path, w.Code, w.Body)
+		}
+		if w := client.do(tc.method, "http://test.com"+tc.path, tc.body); w.Code != http.StatusOK {
+			t.Errorf("Expected %s to accept the next nonce count, got: %d", tc.path, w.Code)
+		}
+	}
+}
+
+func TestHandleDigestAuth_WrongPassword(t *testing.T) {
+	client := &digestClient{t: t, handler: newTestServer(t).Handler(), user: "user", pass: "wrong"}
+	client.do("GET", "http://test.com/digest-auth/auth/user/passwd", "")
+	w := client.do("GET", "http://test.com/digest-auth/auth/user/passwd", "")
+	if w.Code != http.StatusUnauthorized || client.params["stale"] != "FALSE" {
+		t.Errorf("Expected a non-stale challenge, got: %d stale=%s", w.Code, client.params["stale"])
+	}
+}
+
+func TestHandleDigestAuth_Replay(t *testing.T) {
+	client := &digestClient{t: t, handler: newTestServer(t).Handler(), user: "user", pass: "passwd"}
+	client.do("GET", "http://test.com/digest-auth/auth/user/passwd", "")
+	client.do("GET", "http://test.com/digest-auth/auth/user/passwd", "")
+
+	client.nc = 0
+	w := client.do("GET", "http://test.com/digest-auth/auth/user/passwd", "")
+	if w.Code != http.StatusUnauthorized || client.params["stale"] != "TRUE" {
+		t.Errorf("Expected a replayed nonce count to be stale, got: %d stale=%s", w.Code, client.params["stale"])
+	}
+}
+
+func TestHandleDigestAuth_StaleAfter(t *testing.T) {
+	client := &digestClient{t: t, handler: newTestServer(t).Handler(), user: "user", pass: "passwd"}
+	target := "http://test.com/digest-auth/auth/user/passwd/MD5/2"
+	var codes []int
+	for i := 0; i < 5; i++ {
+		codes = append(codes, client.do("GET", target, "").Code)
+	}
+	if fmt.Sprint(codes) != "[401 200 200 401 200]" || client.params["stale"] != "TRUE" {
+		t.Errorf("Expected nonce to go stale after 2 uses, got: %v", codes)
+	}
+}
+
+func TestHandleDigestAuth_RequireCookie(t *testing.T) {
+	client := &digestClient{t: t, handler: newTestServer(t).Handler(), user: "user", pass: "passwd"}
+	target := "http://test.com/digest-auth/auth/user/passwd?require-cookie=true"
+	client.do("GET", target, "")
+	if len(client.cookies) == 0 || client.cookies[0].Value != "fake_value" {
+		t.Fatalf("Expected the challenge to set the fake cookie")
+	}
+	if w := client.do("GET", target, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected to authenticate with the cookie, got: %d", w.Code)
+	}
+	client.cookies = nil
+	if w := client.do("GET", target, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a challenge without the cookie, got: %d", w.Code)
+	}
+}
+
+func TestHandleDigestAuth_Invalid(t *testing.T) {
+	handler := newTestServer(t).Handler()
+	for _, path := range []string{"/digest-auth/foo/user/passwd", "/digest-auth/auth/user/passwd/SHA-1", "/digest-auth/auth/user/passwd/MD5/0"} {
+		w := serve(handler, "GET", path, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got: %d", path, w.Code)
+		}
+	}
+}
+
+func TestParseDigestAuth(t *testing.T) {
+	params, ok := parseDigestAuth(`Digest username="a \"b\"", realm="x, y", nc=00000001, qop=auth`)
+	if !ok {
+		t.Fatalf("Failed to parse header")
+	}
+	if params["username"] != `a "b"` || params["realm"] != "x, y" || params["nc"] != "00000001" || params["qop"] != "auth" {
+		t.Errorf("Unexpected params: %v", params)
+	}
+	if _, ok := parseDigestAuth("Basic dXNlcjpwYXNz"); ok {
+		t.Errorf("Expected Basic credentials to be rejected")
+	}
+}
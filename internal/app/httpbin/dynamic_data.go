@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
@@ -34,21 +33,20 @@ func (s *Server) handleBytes() http.HandlerFunc {
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-		length = math.Min(length, 100*1024) // set 100KB limit
+		length = math.Min(length, float64(s.cfg().MaxBytes))
 
+		rnd := s.random()
 		if seed := r.URL.Query().Get("seed"); seed != "" {
 			i, err := strconv.Atoi(seed)
 			if err == nil {
-				rand.Seed(int64(i))
+				rnd = newLockedRand(int64(i))
 			}
-		} else {
-			rand.Seed(time.Now().UnixNano())
 		}
 
 		w.Header().Add("Content-Type", "application/octet-stream")
 
 		randBytes := make([]byte, int(length))
-		rand.Read(randBytes)
+		rnd.Read(randBytes)
 		w.Write(randBytes)
 	}
 }
@@ -60,17 +58,19 @@ func (s *Server) handleDelay() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 
-		if err := delayRequest(delay); err != nil {
+		if err := s.delayRequest(delay); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 
 		keys := requestKeys{"url", "args", "form", "data", "origin", "headers", "files"}
-		returnRequestAsJSON(w, r, keys)
+		s.returnRequestAsJSON(w, r, keys)
 	}
 }
 
 func (s *Server) handleDrip() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer s.trackStream("drip")()
+
 		query := r.URL.Query()
 		var code string
 		var err error
@@ -91,7 +91,7 @@ func (s *Server) handleDrip() http.HandlerFunc {
 			code = "200"
 		}
 
-		if err := delayRequest(delay); err != nil {
+		if err := s.delayRequest(delay); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -114,7 +114,7 @@ func (s *Server) handleDrip() http.HandlerFunc {
 		pause := duration / numbytes
 		for i := 1; i <= int(numbytes); i++ {
 			fw.Write([]byte("*"))
-			if err := delayRequest(pause); err != nil {
+			if err := s.delayRequest(pause); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -143,7 +143,8 @@ func (s *Server) handleLinks() http.HandlerFunc {
 			if i == int(offset) {
 				html = append(html, fmt.Sprintf("%d ", i))
 			} else {
-				html = append(html, fmt.Sprintf("<a href='/links/%d/%d'>%d</a> ", int(n), i, i))
+				href := s.path(fmt.Sprintf("/links/%d/%d", int(n), i))
+				html = append(html, fmt.Sprintf("<a href='%s'>%d</a> ", href, i))
 			}
 		}
 
@@ -154,15 +155,17 @@ func (s *Server) handleLinks() http.HandlerFunc {
 
 func (s *Server) handleRange() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer s.trackStream("range")()
+
 		numbytes, err := parseURLFloat(mux.Vars(r)["numbytes"], "")
 		if err != nil {
 			http.Error(w, "Invalid numbytes", http.StatusBadRequest)
 			return
 		}
 
-		if numbytes > (100 * 1024) {
+		if maxBytes := s.cfg().MaxBytes; numbytes > float64(maxBytes) {
 			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("number of bytes must be in the range (0, 102400)"))
+			w.Write([]byte(fmt.Sprintf("number of bytes must be in the range (0, %d)", maxBytes)))
 			return
 		}
 
@@ -216,7 +219,7 @@ func (s *Server) handleRange() http.HandlerFunc {
 			chunks = append(chunks, rune(chunk))
 			if len(chunks) == int(chunkSize) {
 				fw.Write([]byte(string(chunks)))
-				if err := delayRequest(pausePerByte * chunkSize); err != nil {
+				if err := s.delayRequest(pausePerByte * chunkSize); err != nil {
 					http.Error(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
@@ -232,7 +235,7 @@ func (s *Server) handleRange() http.HandlerFunc {
 
 			}
 			lastDelay = pausePerByte * lastDelay
-			if err := delayRequest(lastDelay); err != nil {
+			if err := s.delayRequest(lastDelay); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -243,19 +246,20 @@ func (s *Server) handleRange() http.HandlerFunc {
 
 func (s *Server) handleStreamBytes() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer s.trackStream("stream-bytes")()
+
 		length, err := parseURLFloat(mux.Vars(r)["n"], "")
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-		length = math.Min(length, 100*1024) // set 100KB limit
+		length = math.Min(length, float64(s.cfg().MaxBytes))
 
+		rnd := s.random()
 		if seed := r.URL.Query().Get("seed"); seed != "" {
 			i, err := strconv.Atoi(seed)
 			if err == nil {
-				rand.Seed(int64(i))
+				rnd = newLockedRand(int64(i))
 			}
-		} else {
-			rand.Seed(time.Now().UnixNano())
 		}
 
 		var chunkSize float64
@@ -273,7 +277,7 @@ func (s *Server) handleStreamBytes() http.HandlerFunc {
 
 		var chunks []byte
 		for i := 0; i < int(length); i++ {
-			chunks = append(chunks, strconv.Itoa(rand.Intn(255))...)
+			chunks = append(chunks, strconv.Itoa(rnd.Intn(255))...)
 			if len(chunks) == int(chunkSize) {
 				fw.Write(chunks)
 				chunks = []byte{}
@@ -288,13 +292,16 @@ func (s *Server) handleStreamBytes() http.HandlerFunc {
 
 func (s *Server) handleStream() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer s.trackStream("stream")()
+
 		req, err := parseRequest(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeRequestError(w, err)
+			return
 		}
 
 		keys := requestKeys{"url", "args", "origin", "headers"}
-		requestedKeys := req.selectKeys(keys)
+		requestedKeys := s.selectRequestKeys(req, keys)
 
 		numResults, err := parseURLFloat(mux.Vars(r)["n"], "")
 		if err != nil {
@@ -400,7 +407,7 @@ func inRange(i, min, max int) bool {
 	return false
 }
 
-func delayRequest(delay float64) error {
+func (s *Server) delayRequest(delay float64) error {
 	// for delays more granular than 1 sec, a delay of over 1000 will be
 	// parsed as microseconds
 	useMicroS := delay > 999
@@ -408,8 +415,8 @@ func delayRequest(delay float64) error {
 		delay = math.Min(delay, 1000000) // microsecond delay must be less than 1 sec
 		time.Sleep(time.Duration(delay) * time.Microsecond)
 	} else {
-		delay = math.Min(delay, 10) // max of 10 sec delay
-		time.Sleep(time.Duration(delay) * time.Second)
+		delay = math.Min(delay, time.Duration(s.cfg().MaxDelay).Seconds())
+		time.Sleep(time.Duration(delay * float64(time.Second)))
 	}
 
 	return nil
@@ -124,6 +124,21 @@ func TestHandleLinks(t *testing.T) {
 	}
 }
 
+func TestHandleLinks_BasePath(t *testing.T) {
+	server := &Server{config: &Config{BasePath: "/httpbin/"}}
+	target := "http://test.com/httpbin/links/2/0"
+	req := newTestRequest(server.handleLinks(), target, "GET")
+	req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"n": "2", "offset": "0"})
+
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	if !strings.Contains(string(req.rawResponse), "href='/httpbin/links/2/1'") {
+		t.Errorf("Links should include the base path, got: %s", string(req.rawResponse))
+	}
+}
+
 func TestHandleRange(t *testing.T) {
 	numbytes := 5
 	target := fmt.Sprintf("http://test.com/range/%d", numbytes)
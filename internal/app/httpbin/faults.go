@@ -0,0 +1,279 @@
+package httpbin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Faults are requested with this header or query parameter, e.g.
+// "delay=200ms; jitter=100ms; status=500:0.3,200:0.7"
+const (
+	faultHeader = "X-Httpbin-Fault"
+	faultParam  = "_fault"
+)
+
+var errFaultAborted = errors.New("connection aborted by fault injection")
+
+// fault describes the faults applied to a single response
+type fault struct {
+	delay  time.Duration
+	jitter time.Duration
+	// close drops the connection without sending a response
+	close bool
+	// truncate drops the connection after this many body bytes, -1 to
+	// disable
+	truncate int64
+	// contentLength replaces the Content-Length header, -1 to disable
+	contentLength int64
+	// garbage is the number of random bytes appended to the body
+	garbage int
+	status  weightedCodes
+}
+
+// parseFault parses directives separated by semicolons: delay=<duration>,
+// jitter=<duration>, close, truncate=<bytes>, content-length=<bytes>,
+// garbage=<bytes> and status=<code[:weight],...>
+func parseFault(spec string) (*fault, error) {
+	f := &fault{truncate: -1, contentLength: -1}
+	for _, directive := range strings.Split(spec, ";") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		parts := strings.SplitN(directive, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		val := ""
+		if len(parts) == 2 {
+			val = strings.TrimSpace(parts[1])
+		}
+
+		var err error
+		switch name {
+		case "delay":
+			f.delay, err = time.ParseDuration(val)
+		case "jitter":
+			f.jitter, err = time.ParseDuration(val)
+		case "close":
+			f.close = true
+		case "truncate":
+			f.truncate, err = parseFaultSize(val)
+		case "content-length":
+			f.contentLength, err = parseFaultSize(val)
+		case "garbage":
+			var n int64
+			n, err = parseFaultSize(val)
+			f.garbage = int(n)
+		case "status":
+			f.status, err = parseWeightedCodes(val)
+		default:
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault %q: %v", directive, err)
+		}
+	}
+	if f.delay < 0 || f.jitter < 0 {
+		return nil, fmt.Errorf("fault delays must not be negative")
+	}
+	return f, nil
+}
+
+func parseFaultSize(val string) (int64, error) {
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > DefaultMaxBytes*10 {
+		return 0, fmt.Errorf("size out of range")
+	}
+	return n, nil
+}
+
+// injectFaults applies the faults requested through the X-Httpbin-Fault
+// header or _fault query parameter to any route. Both are removed before
+// the request reaches the handler.
+func (s *Server) injectFaults(next http.Handler) http.Handler {
+	if !s.cfg().FaultInjection {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spec := r.Header.Get(faultHeader)
+		query := r.URL.Query()
+		if param := query.Get(faultParam); param != "" {
+			spec = param
+		}
+		if spec == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := parseFault(spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		r.Header.Del(faultHeader)
+		if _, ok := query[faultParam]; ok {
+			r.URL.RawQuery = removeQueryParam(r.URL.RawQuery, faultParam)
+		}
+
+		if delay := f.delay + s.jitter(f.jitter); delay > 0 {
+			if max := time.Duration(s.cfg().MaxDelay); delay > max {
+				delay = max
+			}
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		fw := &faultWriter{ResponseWriter: w, fault: f, server: s}
+		if f.close {
+			fw.abort()
+			return
+		}
+		next.ServeHTTP(fw, r)
+		fw.finish()
+	})
+}
+
+// removeQueryParam drops every pair named name from a raw query string,
+// leaving the order and escaping of the others as they were sent
+func removeQueryParam(rawQuery, name string) string {
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		key := pair
+		if i := strings.IndexByte(key, '='); i >= 0 {
+			key = key[:i]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil && unescaped == name {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, "&")
+}
+
+func (s *Server) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(s.random().Float64() * float64(max))
+}
+
+// faultWriter applies a fault to the response written by a handler
+type faultWriter struct {
+	http.ResponseWriter
+	fault       *fault
+	server      *Server
+	written     int64
+	wroteHeader bool
+	aborted     bool
+	hijacked    bool
+}
+
+func (fw *faultWriter) WriteHeader(code int) {
+	if fw.wroteHeader {
+		return
+	}
+	fw.wroteHeader = true
+
+	if len(fw.fault.status) > 0 {
+		code = fw.fault.status.pick(fw.server.random())
+	}
+	if fw.fault.contentLength >= 0 {
+		fw.Header().Set("Content-Length", strconv.FormatInt(fw.fault.contentLength, 10))
+	} else if fw.fault.garbage > 0 {
+		fw.Header().Del("Content-Length")
+	}
+	fw.ResponseWriter.WriteHeader(code)
+}
+
+func (fw *faultWriter) Write(p []byte) (int, error) {
+	if fw.aborted {
+		return 0, errFaultAborted
+	}
+	if !fw.wroteHeader {
+		fw.WriteHeader(http.StatusOK)
+	}
+
+	if limit := fw.fault.truncate; limit >= 0 && fw.written+int64(len(p)) > limit {
+		n, _ := fw.ResponseWriter.Write(p[:limit-fw.written])
+		fw.written += int64(n)
+		fw.abort()
+		return n, errFaultAborted
+	}
+	n, err := fw.ResponseWriter.Write(p)
+	fw.written += int64(n)
+	return n, err
+}
+
+func (fw *faultWriter) Flush() {
+	if fw.aborted {
+		return
+	}
+	if f, ok := fw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection to the handler, e.g. for a WebSocket
+// upgrade, after which the fault no longer applies to the response
+func (fw *faultWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := fw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		fw.hijacked = true
+	}
+	return conn, buf, err
+}
+
+func (fw *faultWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
+
+// finish appends garbage once the handler has written the response
+func (fw *faultWriter) finish() {
+	if fw.aborted || fw.hijacked {
+		return
+	}
+	if !fw.wroteHeader {
+		fw.WriteHeader(http.StatusOK)
+	}
+	if n := fw.fault.garbage; n > 0 {
+		garbage := make([]byte, n)
+		fw.server.random().Read(garbage)
+		fw.ResponseWriter.Write(garbage)
+	}
+}
+
+// abort flushes what has been written and closes the connection. HTTP/2
+// connections can't be hijacked, so the stream is reset instead.
+func (fw *faultWriter) abort() {
+	fw.aborted = true
+	if f, ok := fw.ResponseWriter.(http.Flusher); ok && fw.wroteHeader {
+		f.Flush()
+	}
+	if hj, ok := fw.ResponseWriter.(http.Hijacker); ok {
+		conn, buf, err := hj.Hijack()
+		if err == nil {
+			buf.Flush()
+			conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
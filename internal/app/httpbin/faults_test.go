@@ -0,0 +1,165 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var faultConfig = &Config{FaultInjection: true, MaxDelay: Duration(time.Second), MaxBytes: DefaultMaxBytes}
+
+func getWithFault(ts *httptest.Server, path, spec string) (*http.Response, []byte, error) {
+	req, _ := http.NewRequest("GET", ts.URL+path, nil)
+	req.Header.Set(faultHeader, spec)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+func TestParseFault(t *testing.T) {
+	f, err := parseFault("delay=200ms; jitter=50ms; truncate=10; content-length=5; garbage=3; status=500:0.5,503")
+	if err != nil {
+		t.Fatalf("Failed to parse fault. Err: %v", err)
+	}
+	if f.delay != 200*time.Millisecond || f.jitter != 50*time.Millisecond || f.truncate != 10 || f.contentLength != 5 || f.garbage != 3 {
+		t.Errorf("Unexpected fault: %+v", f)
+	}
+	if len(f.status) != 2 || f.status[0].weight != 0.5 || f.status[1].weight != 1 {
+		t.Errorf("Unexpected status codes: %+v", f.status)
+	}
+
+	for _, spec := range []string{"explode", "delay=soon", "truncate=-1", "status=abc", "status=500:x", "status=200:0", "status=200:NaN"} {
+		if _, err := parseFault(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestInjectFaults_Status(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	resp, body, err := getWithFault(ts, "/json", "status=503")
+	if err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || len(body) == 0 {
+		t.Errorf("Expected /json payload with status 503, got: %d with %d bytes", resp.StatusCode, len(body))
+	}
+}
+
+func TestInjectFaults_QueryParamIsRemoved(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/get?z=%7e&_fault=status%3D202&a=1+2")
+	if err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected status 202, got: %d", resp.StatusCode)
+	}
+	if len(body) == 0 || strings.Contains(string(body), "_fault") || strings.Contains(string(body), faultHeader) {
+		t.Errorf("Expected fault to be hidden from the handler, got: %s", body)
+	}
+	var echoed struct{ URL string }
+	if json.Unmarshal(body, &echoed); echoed.URL != ts.URL+"/get?z=%7e&a=1+2" {
+		t.Errorf("Expected the rest of the query to be left as sent, got: %s", echoed.URL)
+	}
+}
+
+func TestInjectFaults_Delay(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	start := time.Now()
+	if _, _, err := getWithFault(ts, "/get", "delay=100ms;jitter=50ms"); err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected a delay of at least 100ms, took: %s", elapsed)
+	}
+}
+
+func TestInjectFaults_Close(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	if _, _, err := getWithFault(ts, "/get", "close"); err == nil {
+		t.Errorf("Expected the connection to be closed")
+	}
+}
+
+func TestInjectFaults_Truncate(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	_, body, err := getWithFault(ts, "/bytes/100", "truncate=10")
+	if err == nil {
+		t.Errorf("Expected the truncated body to fail to read")
+	}
+	if len(body) != 10 {
+		t.Errorf("Expected 10 bytes before the connection closed, got: %d", len(body))
+	}
+}
+
+func TestInjectFaults_ContentLength(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	if _, _, err := getWithFault(ts, "/bytes/10", "content-length=20"); err == nil {
+		t.Errorf("Expected a short body to fail to read")
+	}
+}
+
+func TestInjectFaults_Garbage(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	_, body, err := getWithFault(ts, "/bytes/10", "garbage=5")
+	if err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if len(body) != 15 {
+		t.Errorf("Expected 15 bytes, got: %d", len(body))
+	}
+}
+
+func TestInjectFaults_WebSocket(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(faultConfig), WithSeed(1))
+	defer ts.Close()
+
+	conn := dialWebSocket(t, ts, "/ws/echo?_fault=delay%3D10ms", nil)
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("Failed to send message. Err: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Errorf("Expected echo through the fault, got %q. Err: %v", data, err)
+	}
+}
+
+func TestInjectFaults_Disabled(t *testing.T) {
+	config := DefaultConfig()
+	config.AccessLog = AccessLogNone
+	server := newTestServer(t, WithConfig(config))
+	r := httptest.NewRequest("GET", "http://test.com/get", nil)
+	r.Header.Set(faultHeader, "status=500")
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected faults to be ignored, got: %d", w.Code)
+	}
+}
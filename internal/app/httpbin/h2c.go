@@ -0,0 +1,236 @@
+package httpbin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// h2cPreface starts every HTTP/2 connection, RFC 7540 §3.5
+const h2cPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const (
+	http2FrameHeaders  = 0x1
+	http2FrameSettings = 0x4
+
+	http2FlagEndStream  = 0x1
+	http2FlagAck        = 0x1
+	http2FlagEndHeaders = 0x4
+
+	// http2MaxFrameSize is the frame size every HTTP/2 peer must accept
+	http2MaxFrameSize = 16384
+
+	// h2cPrefaceTimeout is how long an upgraded client has to send its
+	// connection preface
+	h2cPrefaceTimeout = 10 * time.Second
+)
+
+// h2cHopHeaders are the HTTP/1.1 headers that can't be carried over HTTP/2
+var h2cHopHeaders = map[string]bool{
+	"connection":        true,
+	"host":              true,
+	"http2-settings":    true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// upgradeH2C switches plain HTTP/1.1 requests with Upgrade: h2c to HTTP/2,
+// RFC 7540 §3.2. http.Server only serves h2c with prior knowledge, so after
+// the 101 response the connection is handed back to it as a new one with
+// the upgraded request replayed as the HEADERS frame of stream 1. Requests
+// with a body, which would have to be sent before the switch, stay on
+// HTTP/1.1.
+func (s *Server) upgradeH2C(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv, ok := r.Context().Value(http.ServerContextKey).(*http.Server)
+		if !ok || srv.Protocols == nil || !srv.Protocols.UnencryptedHTTP2() || r.TLS != nil || !isH2CUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		settings, err := base64.RawURLEncoding.DecodeString(r.Header.Get("HTTP2-Settings"))
+		headers := h2cHeadersFrame(r)
+		hj, ok := w.(http.Hijacker)
+		if err != nil || len(settings)%6 != 0 || headers == nil || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		conn, buf, err := hj.Hijack()
+		if err != nil {
+			return
+		}
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+		if err := buf.Flush(); err != nil {
+			conn.Close()
+			return
+		}
+		go serveH2C(srv, conn, buf.Reader, settings, headers)
+	})
+}
+
+// isH2CUpgrade reports whether a request asks to switch to h2c and has no
+// body
+func isH2CUpgrade(r *http.Request) bool {
+	if r.ProtoMajor != 1 || r.ContentLength != 0 || len(r.TransferEncoding) > 0 || len(r.Header["Http2-Settings"]) != 1 {
+		return false
+	}
+	connection := headerTokens(r.Header, "Connection")
+	return headerTokens(r.Header, "Upgrade")["h2c"] && connection["upgrade"] && connection["http2-settings"]
+}
+
+// headerTokens returns the lowercased comma separated tokens of a header
+func headerTokens(h http.Header, name string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, val := range h.Values(name) {
+		for _, token := range splitList(val) {
+			tokens[strings.ToLower(token)] = true
+		}
+	}
+	return tokens
+}
+
+// serveH2C waits for the client preface of an upgraded connection and
+// serves the connection as HTTP/2 with prior knowledge
+func serveH2C(srv *http.Server, conn net.Conn, br *bufio.Reader, settings, headers []byte) {
+	conn.SetReadDeadline(time.Now().Add(h2cPrefaceTimeout))
+	prelude, err := readH2CPrelude(br, settings)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	l := &h2cListener{conn: &h2cConn{Conn: conn, r: io.MultiReader(bytes.NewReader(append(prelude, headers...)), br)}}
+	srv.Serve(l)
+	if !l.accepted {
+		conn.Close()
+	}
+}
+
+// readH2CPrelude reads the client preface and the SETTINGS frame that must
+// follow it, and returns them with the HTTP2-Settings of the upgrade
+// request merged into the frame ahead of the client's own values, so the
+// server acknowledges both at once
+func readH2CPrelude(br *bufio.Reader, settings []byte) ([]byte, error) {
+	prelude := make([]byte, len(h2cPreface)+9)
+	if _, err := io.ReadFull(br, prelude); err != nil {
+		return nil, err
+	}
+	header := prelude[len(h2cPreface):]
+	length := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+	if string(prelude[:len(h2cPreface)]) != h2cPreface || header[3] != http2FrameSettings || header[4]&http2FlagAck != 0 {
+		return nil, errors.New("expected the HTTP/2 preface and a SETTINGS frame")
+	}
+	if length+len(settings) > http2MaxFrameSize {
+		return nil, errors.New("SETTINGS frame too large")
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, err
+	}
+	frame := http2Frame(http2FrameSettings, 0, 0, append(settings, payload...))
+	return append([]byte(h2cPreface), frame...), nil
+}
+
+// h2cHeadersFrame encodes an upgraded request as the HEADERS frame opening
+// stream 1, with HPACK literals that are neither indexed nor Huffman coded.
+// It returns nil when the request doesn't fit in a single frame.
+func h2cHeadersFrame(r *http.Request) []byte {
+	if !strings.HasPrefix(r.RequestURI, "/") {
+		return nil
+	}
+
+	var block []byte
+	field := func(name, value string) {
+		block = append(block, 0)
+		block = hpackString(block, name)
+		block = hpackString(block, value)
+	}
+	field(":method", r.Method)
+	field(":scheme", "http")
+	field(":authority", r.Host)
+	field(":path", r.RequestURI)
+
+	connection := headerTokens(r.Header, "Connection")
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if h2cHopHeaders[name] || connection[name] {
+			continue
+		}
+		for _, value := range values {
+			if name == "te" && value != "trailers" {
+				continue
+			}
+			field(name, value)
+		}
+	}
+	if len(block) > http2MaxFrameSize {
+		return nil
+	}
+	return http2Frame(http2FrameHeaders, http2FlagEndHeaders|http2FlagEndStream, 1, block)
+}
+
+// hpackString appends a string literal with a 7-bit prefixed length,
+// RFC 7541 §5.1-5.2
+func hpackString(dst []byte, s string) []byte {
+	n := len(s)
+	if n < 127 {
+		dst = append(dst, byte(n))
+	} else {
+		dst = append(dst, 127)
+		for n -= 127; n >= 128; n /= 128 {
+			dst = append(dst, byte(n%128+128))
+		}
+		dst = append(dst, byte(n))
+	}
+	return append(dst, s...)
+}
+
+func http2Frame(frameType, flags byte, stream uint32, payload []byte) []byte {
+	n := len(payload)
+	frame := []byte{byte(n >> 16), byte(n >> 8), byte(n), frameType, flags,
+		byte(stream >> 24), byte(stream >> 16), byte(stream >> 8), byte(stream)}
+	return append(frame, payload...)
+}
+
+// h2cConn is an upgraded connection whose reads start with the replayed
+// preface and request
+type h2cConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *h2cConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// h2cListener hands a single upgraded connection to http.Server.Serve
+type h2cListener struct {
+	conn     net.Conn
+	accepted bool
+}
+
+func (l *h2cListener) Accept() (net.Conn, error) {
+	if l.accepted {
+		return nil, errors.New("h2c connection already accepted")
+	}
+	l.accepted = true
+	return l.conn, nil
+}
+
+func (l *h2cListener) Close() error {
+	return nil
+}
+
+func (l *h2cListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
@@ -4,11 +4,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/nathanows/httpbin-go/pkg/jsonparser"
 )
 
-var possibleResponseFields = []string{"args", "authenticated", "cookies", "data", "files", "form", "headers", "json", "method", "origin", "token", "url", "user", "user-agent", "uuid"}
+var possibleResponseFields = []string{"args", "authenticated", "cookies", "data", "files", "form", "headers", "http2", "json", "method", "origin", "protocol", "token", "url", "user", "user-agent", "uuid"}
 
 type jsonAssertion []struct {
 	jsonPath string
@@ -27,6 +31,7 @@ type testRequest struct {
 	handlerFunc http.HandlerFunc
 	target      string
 	method      string
+	body        string
 	headers     map[string][]string
 	status      []int // with multiple status codes a random status is returned
 
@@ -48,6 +53,12 @@ func testReqHeaders(headers map[string][]string) func(*testRequest) {
 	}
 }
 
+func testReqBody(body string) func(*testRequest) {
+	return func(tr *testRequest) {
+		tr.body = body
+	}
+}
+
 func testReqStatus(codes []int) func(*testRequest) {
 	return func(tr *testRequest) {
 		tr.status = codes
@@ -67,12 +78,53 @@ func newTestRequest(handlerFunc http.HandlerFunc, target, method string, opts ..
 		opt(tr)
 	}
 
-	req, _ := http.NewRequest(tr.method, tr.target, nil)
+	req, _ := http.NewRequest(tr.method, tr.target, strings.NewReader(tr.body))
 	tr.baseRequest = req
 
 	return tr
 }
 
+// newTestServer builds a Server with the given options, failing the test
+// when they are invalid. It starts from the zero Config, so nothing is
+// logged unless a config asks for it.
+func newTestServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	server, err := NewServer(mux.NewRouter(), append([]Option{WithConfig(&Config{})}, opts...)...)
+	if err != nil {
+		t.Fatalf("Failed to create server. Err: %v", err)
+	}
+	return server
+}
+
+// newHTTPTestServer starts an httptest.Server for the protocols and
+// connection tracking httpServer sets up
+func newHTTPTestServer(t *testing.T, opts ...Option) *httptest.Server {
+	t.Helper()
+	srv, err := newTestServer(t, opts...).httpServer()
+	if err != nil {
+		t.Fatalf("Failed to build http server. Err: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	ts.Config.ConnContext = srv.ConnContext
+	ts.Config.Protocols = srv.Protocols
+	ts.Start()
+	return ts
+}
+
+// withTestClock makes the server read the time from *now, so a test can
+// move it
+func withTestClock(now *time.Time) Option {
+	return WithClock(func() time.Time { return *now })
+}
+
+// serve makes a request to a full handler, such as Server.Handler, rather
+// than a single route
+func serve(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, "http://test.com"+target, strings.NewReader(body)))
+	return w
+}
+
 func (tr *testRequest) make() error {
 	if len(tr.headers) > 0 {
 		tr.baseRequest.Header = tr.headers
@@ -123,7 +175,7 @@ func (tr *testRequest) runTestCases(testCases jsonAssertion) error {
 
 func (tr *testRequest) validateCorrectFields(expected []string) error {
 	for _, field := range expected {
-		if val := tr.parsedJSON.Path(field).Data(); val == nil {
+		if val := tr.parsedJSON.Path(field); val == nil {
 			return fmt.Errorf("Expected field %s to be included in response", field)
 		}
 	}
@@ -131,7 +183,7 @@ func (tr *testRequest) validateCorrectFields(expected []string) error {
 	expectedNotIncluced := sliceDiff(possibleResponseFields, expected)
 
 	for _, field := range expectedNotIncluced {
-		if val := tr.parsedJSON.Path(field).Data(); val != nil {
+		if val := tr.parsedJSON.Path(field); val != nil {
 			return fmt.Errorf("%s should not be included in response, got: %s", field, tr.parsedJSON.Path(field).String())
 		}
 	}
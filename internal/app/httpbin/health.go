@@ -0,0 +1,45 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+type healthResponse struct {
+	Status string `json:"status"`
+}
+
+func (s *Server) handleHealthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, "ok")
+	}
+}
+
+func (s *Server) handleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.isDraining() {
+			writeHealth(w, http.StatusServiceUnavailable, "draining")
+			return
+		}
+		writeHealth(w, http.StatusOK, "ready")
+	}
+}
+
+func (s *Server) isDraining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+func writeHealth(w http.ResponseWriter, code int, status string) {
+	jsonResp, err := json.Marshal(healthResponse{Status: status})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonResp = append(jsonResp, "\n"...)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	w.Write(jsonResp)
+}
@@ -0,0 +1,206 @@
+package httpbin
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHandleHealthz(t *testing.T) {
+	target := "http://test.com/healthz"
+	req := newTestRequest(reqInspectServer.handleHealthz(), target, "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.parsedJSON.Path("status").String(); val != "ok" {
+		t.Errorf("Expected status to be ok, got: %s", val)
+	}
+}
+
+func TestHandleReadyz_Draining(t *testing.T) {
+	server := &Server{}
+	target := "http://test.com/readyz"
+
+	req := newTestRequest(server.handleReadyz(), target, "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+
+	server.draining = 1
+	req = newTestRequest(server.handleReadyz(), target, "GET", testReqStatus([]int{http.StatusServiceUnavailable}))
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.parsedJSON.Path("status").String(); val != "draining" {
+		t.Errorf("Expected status to be draining, got: %s", val)
+	}
+}
+
+// startServe runs server.serve on a local listener, returning its base URL,
+// the channel that stops it and the channel its result is sent on
+func startServe(t *testing.T, cfg *Config) (string, chan<- os.Signal, <-chan error) {
+	server := newTestServer(t, WithConfig(cfg))
+	srv, err := server.httpServer()
+	if err != nil {
+		t.Fatalf("Failed to build http server. Err: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen. Err: %v", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() { served <- server.serve(srv, l, stop) }()
+	return "http://" + l.Addr().String(), stop, served
+}
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ShutdownDelay = Duration(200 * time.Millisecond)
+	baseURL, stop, served := startServe(t, cfg)
+
+	inFlight := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(baseURL + "/delay/500000")
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	stop <- syscall.SIGTERM
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get(baseURL + "/readyz")
+	if err != nil {
+		t.Fatalf("Server should keep serving during the shutdown delay. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected /readyz to return 503 while draining, got: %d", resp.StatusCode)
+	}
+
+	if code := <-inFlight; code != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete with 200, got: %d", code)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, got: %v", err)
+	}
+}
+
+func TestServe_GracefulShutdownH2C(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AccessLog = AccessLogNone
+	baseURL, stop, served := startServe(t, cfg)
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+
+	inFlight := make(chan string, 1)
+	go func() {
+		resp, err := client.Get(baseURL + "/drip?duration=0.5&numbytes=5&delay=0")
+		if err != nil {
+			inFlight <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			inFlight <- err.Error()
+			return
+		}
+		inFlight <- resp.Proto + " " + string(body)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	stop <- syscall.SIGTERM
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, got: %v", err)
+	}
+	select {
+	case got := <-inFlight:
+		if got != "HTTP/2.0 *****" {
+			t.Errorf("Expected the h2c stream to complete, got: %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the h2c stream to complete before shutdown finished")
+	}
+}
+
+func TestServe_GracefulShutdownWebSocket(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AccessLog = AccessLogNone
+	baseURL, stop, served := startServe(t, cfg)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(baseURL, "http")+"/ws/stream/3?delay=0.2", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial. Err: %v", err)
+	}
+	defer conn.Close()
+
+	var received int32
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			atomic.AddInt32(&received, 1)
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	stop <- syscall.SIGTERM
+
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, got: %v", err)
+	}
+	if n := atomic.LoadInt32(&received); n != 3 {
+		t.Errorf("Expected the WebSocket stream to complete before shutdown finished, got %d messages", n)
+	}
+}
+
+func TestServe_DrainTimeoutClosesHijacked(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.AccessLog = AccessLogNone
+	cfg.DrainTimeout = Duration(100 * time.Millisecond)
+	baseURL, stop, served := startServe(t, cfg)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(baseURL, "http")+"/ws/echo", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial. Err: %v", err)
+	}
+	defer conn.Close()
+
+	stop <- syscall.SIGTERM
+	if err := <-served; err == nil || !strings.Contains(err.Error(), "hijacked") {
+		t.Errorf("Expected the drain timeout to be reported, got: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsUnexpectedCloseError(err) {
+		t.Errorf("Expected the connection to be closed, got: %v", err)
+	}
+}
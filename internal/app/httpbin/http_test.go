@@ -1,6 +1,11 @@
 package httpbin
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
 
 var httpServer = &Server{}
 
@@ -24,7 +29,7 @@ func TestHandleDelete(t *testing.T) {
 	if err := req.runTestCases(testCases); err != nil {
 		t.Errorf("Failed test case. Failure: %v", err)
 	}
-	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "url"}
+	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "protocol", "url"}
 	if err := req.validateCorrectFields(expectedResponseKeys); err != nil {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
@@ -50,7 +55,7 @@ func TestHandleGet(t *testing.T) {
 	if err := req.runTestCases(testCases); err != nil {
 		t.Errorf("Failed test case. Failure: %v", err)
 	}
-	expectedResponseKeys := []string{"args", "headers", "origin", "url"}
+	expectedResponseKeys := []string{"args", "headers", "origin", "protocol", "url"}
 	if err := req.validateCorrectFields(expectedResponseKeys); err != nil {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
@@ -76,12 +81,33 @@ func TestHandlePatch(t *testing.T) {
 	if err := req.runTestCases(testCases); err != nil {
 		t.Errorf("Failed test case. Failure: %v", err)
 	}
-	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "url"}
+	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "protocol", "url"}
 	if err := req.validateCorrectFields(expectedResponseKeys); err != nil {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
 }
 
+func TestHandlePost_JSONBody(t *testing.T) {
+	target := "http://test.com/post"
+	headers := map[string][]string{"Content-Type": []string{"application/json"}}
+	req := newTestRequest(httpServer.handlePost(), target, "POST", testReqHeaders(headers), testReqBody(`{"name": "steve"}`))
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	testCases := jsonAssertion{
+		{"json.name", "steve"},
+		{"data", `{"name": "steve"}`},
+	}
+
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if err := req.runTestCases(testCases); err != nil {
+		t.Errorf("Failed test case. Failure: %v", err)
+	}
+}
+
 func TestHandlePut(t *testing.T) {
 	target := "http://test.com/put?something=put"
 	headers := map[string][]string{"Accept": []string{"*/*"}}
@@ -102,7 +128,7 @@ func TestHandlePut(t *testing.T) {
 	if err := req.runTestCases(testCases); err != nil {
 		t.Errorf("Failed test case. Failure: %v", err)
 	}
-	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "url"}
+	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "protocol", "url"}
 	if err := req.validateCorrectFields(expectedResponseKeys); err != nil {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
@@ -128,8 +154,41 @@ func TestHandlePost(t *testing.T) {
 	if err := req.runTestCases(testCases); err != nil {
 		t.Errorf("Failed test case. Failure: %v", err)
 	}
-	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "url"}
+	expectedResponseKeys := []string{"args", "data", "files", "form", "headers", "json", "origin", "protocol", "url"}
 	if err := req.validateCorrectFields(expectedResponseKeys); err != nil {
 		t.Errorf("Incorrect response keys returned. Failure: %v", err)
 	}
 }
+
+func TestHandlePost_MalformedMultipart(t *testing.T) {
+	target := "http://test.com/post"
+	headers := map[string][]string{"Content-Type": []string{"multipart/form-data; boundary=xyz"}}
+	req := newTestRequest(httpServer.handlePost(), target, "POST", testReqHeaders(headers), testReqBody("--xyz\r\nnot a part"))
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	if req.response.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got: %d", req.response.Code)
+	}
+	if body := req.response.Body.String(); !strings.HasPrefix(body, "failed to parse multipart body") || strings.Contains(body, "{") {
+		t.Errorf("Expected only the parse error in the body, got: %q", body)
+	}
+}
+
+func TestHandlePost_BodyTooLarge(t *testing.T) {
+	server := newTestServer(t, WithConfig(&Config{MaxBodySize: 16}))
+
+	// a chunked body has no Content-Length, so it is only caught while read
+	r := httptest.NewRequest("POST", "http://test.com/post", strings.NewReader(strings.Repeat("a", 32)))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got: %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "{") {
+		t.Errorf("Expected only the error in the body, got: %q", w.Body.String())
+	}
+}
@@ -6,10 +6,10 @@ import (
 )
 
 var images = map[string]string{
-	"image/jpeg":    "images/jackal.jpg",
-	"image/png":     "images/pig_icon.png",
-	"image/webp":    "images/wolf_1.webp",
-	"image/svg+xml": "images/svg_logo.svg",
+	"image/jpeg":    "jackal.jpg",
+	"image/png":     "pig_icon.png",
+	"image/webp":    "wolf_1.webp",
+	"image/svg+xml": "svg_logo.svg",
 }
 
 func (s *Server) handleImage(imageType string) http.HandlerFunc {
@@ -22,12 +22,12 @@ func (s *Server) handleImage(imageType string) http.HandlerFunc {
 				imgType = "image/png"
 			}
 		}
-		imgPath, ok := images[imgType]
+		imgFile, ok := images[imgType]
 		if !ok {
 			w.WriteHeader(http.StatusUnsupportedMediaType)
 			return
 		}
 		w.Header().Set("Content-Type", imgType)
-		http.ServeFile(w, r, imgPath)
+		http.ServeFileFS(w, r, s.assets().images, imgFile)
 	}
 }
@@ -1,19 +1,14 @@
 package httpbin
 
-import (
-	"os"
-	"testing"
-)
+import "testing"
 
 func TestHandleImage(t *testing.T) {
 	target := "http://test.com/image"
 	headers := map[string][]string{"accept": []string{"image/png"}}
 	req := newTestRequest(reqInspectServer.handleImage(""), target, "GET", testReqHeaders(headers))
-	os.Chdir("../../..")
 	if err := req.make(); err != nil {
 		t.Errorf("Failed to make request. Err: %v", err)
 	}
-	os.Chdir("internal/app/httpbin")
 
 	if err := req.validateStatusCode(); err != nil {
 		t.Errorf("Failed request base validations. Failure: %v", err)
@@ -32,11 +27,9 @@ func TestHandleImage_DirectRoute(t *testing.T) {
 	target := "http://test.com/image/jpeg"
 	headers := map[string][]string{"accept": []string{"image/png"}}
 	req := newTestRequest(reqInspectServer.handleImage("image/jpeg"), target, "GET", testReqHeaders(headers))
-	os.Chdir("../../..")
 	if err := req.make(); err != nil {
 		t.Errorf("Failed to make request. Err: %v", err)
 	}
-	os.Chdir("internal/app/httpbin")
 
 	if err := req.validateStatusCode(); err != nil {
 		t.Errorf("Failed request base validations. Failure: %v", err)
@@ -0,0 +1,266 @@
+package httpbin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+)
+
+// jwk is a JSON Web Key of RFC 7517. Private key parameters are only read
+// when loading keys and never written.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwtKey is a key that verifies, and when the private key is known signs,
+// JWTs
+type jwtKey struct {
+	kid     string
+	alg     string
+	public  crypto.PublicKey
+	private crypto.Signer
+}
+
+// jwtKeySet holds the asymmetric keys used for JWTs
+type jwtKeySet struct {
+	keys []*jwtKey
+}
+
+var (
+	defaultJWTKeysOnce sync.Once
+	defaultJWTKeys     *jwtKeySet
+)
+
+// jwtKeys returns the keys loaded from Config.JWKSFile or, without one, a
+// set of keys generated once per process for every supported algorithm
+func (s *Server) jwtKeys() *jwtKeySet {
+	if s.jwtKeySet != nil {
+		return s.jwtKeySet
+	}
+	defaultJWTKeysOnce.Do(func() {
+		keys, err := generateJWTKeys()
+		if err != nil {
+			panic(fmt.Sprintf("unable to generate JWT keys: %v", err))
+		}
+		defaultJWTKeys = keys
+	})
+	return defaultJWTKeys
+}
+
+func generateJWTKeys() (*jwtKeySet, error) {
+	set := &jwtKeySet{}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	set.keys = append(set.keys, &jwtKey{kid: "rsa", public: rsaKey.Public(), private: rsaKey})
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		ecKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		kid := "ec-" + curve.Params().Name
+		set.keys = append(set.keys, &jwtKey{kid: kid, public: ecKey.Public(), private: ecKey})
+	}
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	set.keys = append(set.keys, &jwtKey{kid: "ed25519", public: edPublic, private: edPrivate})
+	return set, nil
+}
+
+// loadJWKS reads a JWKS file, keys with private parameters can also sign
+func loadJWKS(path string) (*jwtKeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JWKS: %v", err)
+	}
+	var jwks jwkSet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("unable to parse JWKS %s: %v", path, err)
+	}
+
+	set := &jwtKeySet{}
+	for i, k := range jwks.Keys {
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d in JWKS %s: %v", i, path, err)
+		}
+		set.keys = append(set.keys, key)
+	}
+	return set, nil
+}
+
+func (k jwk) key() (*jwtKey, error) {
+	key := &jwtKey{kid: k.Kid, alg: k.Alg}
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		public := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		key.public = public
+		if k.D != "" {
+			d, err := decodeBigInt(k.D)
+			if err != nil {
+				return nil, err
+			}
+			p, err := decodeBigInt(k.P)
+			if err != nil {
+				return nil, err
+			}
+			q, err := decodeBigInt(k.Q)
+			if err != nil {
+				return nil, err
+			}
+			private := &rsa.PrivateKey{PublicKey: *public, D: d, Primes: []*big.Int{p, q}}
+			if err := private.Validate(); err != nil {
+				return nil, err
+			}
+			private.Precompute()
+			key.private = private
+		}
+	case "EC":
+		curve, ok := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		public := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		key.public = public
+		if k.D != "" {
+			d, err := decodeBigInt(k.D)
+			if err != nil {
+				return nil, err
+			}
+			key.private = &ecdsa.PrivateKey{PublicKey: *public, D: d}
+		}
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key")
+		}
+		key.public = ed25519.PublicKey(x)
+		if k.D != "" {
+			seed, err := base64.RawURLEncoding.DecodeString(k.D)
+			if err != nil || len(seed) != ed25519.SeedSize {
+				return nil, fmt.Errorf("invalid Ed25519 private key")
+			}
+			key.private = ed25519.NewKeyFromSeed(seed)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	return key, nil
+}
+
+// publicJWKS returns the public half of the keys as a JWKS
+func (set *jwtKeySet) publicJWKS() jwkSet {
+	jwks := jwkSet{Keys: []jwk{}}
+	for _, key := range set.keys {
+		k := jwk{Kid: key.kid, Alg: key.alg, Use: "sig"}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			k.Kty = "RSA"
+			k.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			k.Kty = "EC"
+			k.Crv = public.Curve.Params().Name
+			k.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, size)))
+			k.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			k.Kty = "OKP"
+			k.Crv = "Ed25519"
+			k.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks.Keys = append(jwks.Keys, k)
+	}
+	return jwks
+}
+
+// find returns the keys usable with alg, limited to kid when given
+func (set *jwtKeySet) find(alg, kid string, needPrivate bool) []*jwtKey {
+	var keys []*jwtKey
+	for _, key := range set.keys {
+		if kid != "" && key.kid != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		if needPrivate && key.private == nil {
+			continue
+		}
+		if keyMatchesAlg(key.public, alg) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func keyMatchesAlg(public crypto.PublicKey, alg string) bool {
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" || alg == "RS384" || alg == "RS512"
+	case *ecdsa.PublicKey:
+		return map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}[alg] == public.Curve.Params().Name
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid key parameter %q", s)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
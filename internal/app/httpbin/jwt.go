@@ -0,0 +1,484 @@
+package httpbin
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers the hashes of the JWS algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultJWTExpiry is the lifetime of tokens minted by /jwt/issue without
+// an expires_in
+const defaultJWTExpiry = time.Hour
+
+// jwtHashes maps the supported JWS algorithms to the hash they sign with,
+// EdDSA hashes internally
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// jwtValidation holds the checks applied to a token's claims
+type jwtValidation struct {
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+type jwtResponse struct {
+	Authenticated bool                   `json:"authenticated"`
+	Header        map[string]interface{} `json:"header,omitempty"`
+	Claims        map[string]interface{} `json:"claims,omitempty"`
+	ExpiresIn     *int64                 `json:"expires_in,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	Description   string                 `json:"error_description,omitempty"`
+}
+
+// handleJWT validates the bearer token as a JWT and responds with its
+// decoded header and claims, or a 401 with an RFC 6750 challenge naming
+// the reason it was rejected
+func (s *Server) handleJWT() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		validation, err := s.jwtValidation(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="httpbin"`)
+			writeJSON(w, http.StatusUnauthorized, jwtResponse{})
+			return
+		}
+
+		header, claims, err := s.verifyJWT(token, validation)
+		if err != nil {
+			description := strings.NewReplacer(`"`, "'", `\`, "/").Replace(err.Error())
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="httpbin", error="invalid_token", error_description="%s"`, description))
+			writeJSON(w, http.StatusUnauthorized, jwtResponse{Error: "invalid_token", Description: description})
+			return
+		}
+
+		resp := jwtResponse{Authenticated: true, Header: header, Claims: claims}
+		if exp, ok := claims["exp"].(float64); ok {
+			expiresIn := int64(exp) - s.now().Unix()
+			resp.ExpiresIn = &expiresIn
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// jwtValidation returns the configured claim checks, overridden by the
+// iss, aud and leeway query parameters
+func (s *Server) jwtValidation(r *http.Request) (jwtValidation, error) {
+	cfg := s.cfg()
+	v := jwtValidation{issuer: cfg.JWTIssuer, audience: cfg.JWTAudience, leeway: time.Duration(cfg.JWTLeeway)}
+
+	query := r.URL.Query()
+	if iss := query.Get("iss"); iss != "" {
+		v.issuer = iss
+	}
+	if aud := query.Get("aud"); aud != "" {
+		v.audience = aud
+	}
+	if leeway := query.Get("leeway"); leeway != "" {
+		d, err := parseSeconds(leeway)
+		if err != nil || d < 0 {
+			return v, fmt.Errorf("invalid leeway %q", leeway)
+		}
+		v.leeway = d
+	}
+	return v, nil
+}
+
+// bearerToken returns the token of a Bearer Authorization header
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// verifyJWT checks the signature and time, issuer and audience claims of a
+// compact serialized JWT and returns its decoded header and claims
+func (s *Server) verifyJWT(token string, v jwtValidation) (map[string]interface{}, map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("token must have 3 segments, got %d", len(parts))
+	}
+
+	var header, claims map[string]interface{}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, nil, fmt.Errorf("invalid header: %v", err)
+	}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, nil, fmt.Errorf("invalid claims: %v", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid signature encoding")
+	}
+
+	alg, _ := header["alg"].(string)
+	kid, _ := header["kid"].(string)
+	if err := s.verifyJWTSignature(alg, kid, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, nil, err
+	}
+	if err := checkJWTClaims(claims, v, s.now()); err != nil {
+		return nil, nil, err
+	}
+	return header, claims, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("not base64url encoded")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("not a JSON object")
+	}
+	return nil
+}
+
+func (s *Server) verifyJWTSignature(alg, kid string, signed, sig []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		secret := s.cfg().JWTSecret
+		if secret == "" {
+			return fmt.Errorf("no secret configured for %s", alg)
+		}
+		mac := hmac.New(hash.New, []byte(secret))
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("signature verification failed")
+		}
+		return nil
+	}
+
+	keys := s.jwtKeys().find(alg, kid, false)
+	if len(keys) == 0 {
+		if kid != "" {
+			return fmt.Errorf("no %s key with kid '%s'", alg, kid)
+		}
+		return fmt.Errorf("no key for %s", alg)
+	}
+	for _, key := range keys {
+		if verifyAsymmetric(key.public, hash, signed, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature verification failed")
+}
+
+func verifyAsymmetric(public crypto.PublicKey, hash crypto.Hash, signed, sig []byte) bool {
+	if public, ok := public.(ed25519.PublicKey); ok {
+		return ed25519.Verify(public, signed, sig)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(public, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(public, digest, r, s)
+	}
+	return false
+}
+
+// checkJWTClaims checks the exp, nbf and iat claims against now, allowing
+// for the leeway, and the iss and aud claims against those expected
+func checkJWTClaims(claims map[string]interface{}, v jwtValidation, now time.Time) error {
+	times := make(map[string]time.Time)
+	for _, name := range []string{"exp", "nbf", "iat"} {
+		val, ok := claims[name]
+		if !ok {
+			continue
+		}
+		secs, ok := val.(float64)
+		if !ok {
+			return fmt.Errorf("%s claim must be a number", name)
+		}
+		times[name] = time.Unix(int64(secs), 0).UTC()
+	}
+
+	if exp, ok := times["exp"]; ok && !now.Before(exp.Add(v.leeway)) {
+		return fmt.Errorf("token expired at %s", exp.Format(time.RFC3339))
+	}
+	if nbf, ok := times["nbf"]; ok && now.Add(v.leeway).Before(nbf) {
+		return fmt.Errorf("token not valid before %s", nbf.Format(time.RFC3339))
+	}
+	if iat, ok := times["iat"]; ok && now.Add(v.leeway).Before(iat) {
+		return fmt.Errorf("token issued in the future at %s", iat.Format(time.RFC3339))
+	}
+
+	if v.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return fmt.Errorf("issuer '%s' does not match '%s'", iss, v.issuer)
+		}
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return fmt.Errorf("audience does not include '%s'", v.audience)
+	}
+	return nil
+}
+
+// hasAudience reports whether the aud claim, a string or an array of
+// strings, contains audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwtIssueRequest describes a token to mint, read from a JSON body and
+// overridden by query parameters
+type jwtIssueRequest struct {
+	Alg       string                 `json:"alg"`
+	Kid       string                 `json:"kid"`
+	Claims    map[string]interface{} `json:"claims"`
+	ExpiresIn *Duration              `json:"expires_in"`
+}
+
+type jwtIssueResponse struct {
+	Token     string                 `json:"token"`
+	TokenType string                 `json:"token_type"`
+	ExpiresIn int64                  `json:"expires_in"`
+	Header    map[string]interface{} `json:"header"`
+	Claims    map[string]interface{} `json:"claims"`
+}
+
+// jwtIssueParams are the query parameters of /jwt/issue that aren't claims
+var jwtIssueParams = map[string]bool{"alg": true, "kid": true, "expires_in": true}
+
+// handleJWTIssue mints a token with the requested algorithm and claims.
+// iat, exp, jti and the configured iss and aud are filled in unless given;
+// a negative expires_in mints an already expired token.
+func (s *Server) handleJWTIssue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := parseJWTIssueRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cfg := s.cfg()
+		if req.Alg == "" {
+			req.Alg = "RS256"
+			if cfg.JWTSecret != "" {
+				req.Alg = "HS256"
+			}
+		}
+		expiresIn := defaultJWTExpiry
+		if req.ExpiresIn != nil {
+			expiresIn = time.Duration(*req.ExpiresIn)
+		}
+
+		now := s.now()
+		claims := map[string]interface{}{
+			"iat": now.Unix(),
+			"exp": now.Add(expiresIn).Unix(),
+			"jti": uuid.New().String(),
+		}
+		if cfg.JWTIssuer != "" {
+			claims["iss"] = cfg.JWTIssuer
+		}
+		if cfg.JWTAudience != "" {
+			claims["aud"] = cfg.JWTAudience
+		}
+		for name, val := range req.Claims {
+			claims[name] = val
+		}
+
+		header := map[string]interface{}{"alg": req.Alg, "typ": "JWT"}
+		token, err := s.signJWT(header, claims, req.Kid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := jwtIssueResponse{Token: token, TokenType: "Bearer", Header: header, Claims: claims}
+		switch exp := claims["exp"].(type) {
+		case int64:
+			resp.ExpiresIn = exp - now.Unix()
+		case float64:
+			resp.ExpiresIn = int64(exp) - now.Unix()
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func parseJWTIssueRequest(r *http.Request) (jwtIssueRequest, error) {
+	var req jwtIssueRequest
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return req, fmt.Errorf("unable to read body: %v", err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req, fmt.Errorf("invalid JSON body: %v", err)
+		}
+	}
+	if req.Claims == nil {
+		req.Claims = make(map[string]interface{})
+	}
+
+	query := r.URL.Query()
+	if alg := query.Get("alg"); alg != "" {
+		req.Alg = alg
+	}
+	if kid := query.Get("kid"); kid != "" {
+		req.Kid = kid
+	}
+	if val := query.Get("expires_in"); val != "" {
+		d, err := parseSeconds(val)
+		if err != nil {
+			return req, fmt.Errorf("invalid expires_in %q", val)
+		}
+		expiresIn := Duration(d)
+		req.ExpiresIn = &expiresIn
+	}
+	for name, vals := range query {
+		if jwtIssueParams[name] {
+			continue
+		}
+		switch name {
+		case "exp", "nbf", "iat":
+			n, err := strconv.ParseInt(vals[0], 10, 64)
+			if err != nil {
+				return req, fmt.Errorf("invalid %s %q", name, vals[0])
+			}
+			req.Claims[name] = n
+		default:
+			req.Claims[name] = vals[0]
+		}
+	}
+	return req, nil
+}
+
+// signJWT returns the compact serialization of a token signed with the
+// algorithm in the header, adding the kid of the key used
+func (s *Server) signJWT(header, claims map[string]interface{}, kid string) (string, error) {
+	alg, _ := header["alg"].(string)
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	var key *jwtKey
+	if !strings.HasPrefix(alg, "HS") {
+		keys := s.jwtKeys().find(alg, kid, true)
+		if len(keys) == 0 {
+			return "", fmt.Errorf("no private key for %s", alg)
+		}
+		key = keys[0]
+		if key.kid != "" {
+			header["kid"] = key.kid
+		}
+	} else if s.cfg().JWTSecret == "" {
+		return "", fmt.Errorf("no secret configured for %s", alg)
+	}
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." +
+		base64.RawURLEncoding.EncodeToString(encodedClaims)
+
+	var sig []byte
+	if key == nil {
+		mac := hmac.New(hash.New, []byte(s.cfg().JWTSecret))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	} else if sig, err = signAsymmetric(key.private, hash, []byte(signed)); err != nil {
+		return "", fmt.Errorf("unable to sign token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func signAsymmetric(private crypto.Signer, hash crypto.Hash, signed []byte) ([]byte, error) {
+	if private, ok := private.(ed25519.PrivateKey); ok {
+		return ed25519.Sign(private, signed), nil
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, private, hash, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, private, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (private.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", private)
+}
+
+// handleJWKS serves the public keys that verify asymmetrically signed
+// tokens
+func (s *Server) handleJWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.jwtKeys().publicJWKS())
+	}
+}
+
+// parseSeconds parses a duration such as 30s or a number of seconds
+func parseSeconds(val string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(val, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	return time.ParseDuration(val)
+}
@@ -0,0 +1,147 @@
+package httpbin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var jwtTestTime = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// jwtTestClock stops the server clock at jwtTestTime
+var jwtTestClock = WithClock(func() time.Time { return jwtTestTime })
+
+func issueJWT(t *testing.T, handler http.Handler, target, body string) jwtIssueResponse {
+	w := serve(handler, "POST", target, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Failed to issue token from %s. Status: %d, Body: %s", target, w.Code, w.Body.String())
+	}
+	var resp jwtIssueResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse issued token. Err: %v", err)
+	}
+	return resp
+}
+
+func serveJWT(handler http.Handler, target, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", target, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandleJWT(t *testing.T) {
+	now := jwtTestTime
+	handler := newTestServer(t, WithConfig(&Config{JWTSecret: "secret", JWTIssuer: "https://issuer.test"}), withTestClock(&now)).Handler()
+
+	for _, alg := range []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"} {
+		issued := issueJWT(t, handler, "/jwt/issue?alg="+alg+"&sub=alice&expires_in=60", "")
+		w := serveJWT(handler, "/jwt", issued.Token)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %s token to validate. Status: %d, Challenge: %s", alg, w.Code, w.Header().Get("WWW-Authenticate"))
+			continue
+		}
+		var resp jwtResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Header["alg"] != alg || resp.Claims["sub"] != "alice" || resp.Claims["iss"] != "https://issuer.test" {
+			t.Errorf("Unexpected %s header or claims. Header: %v, Claims: %v", alg, resp.Header, resp.Claims)
+		}
+		if resp.ExpiresIn == nil || *resp.ExpiresIn != 60 {
+			t.Errorf("Expected %s token to expire in 60s, got %v", alg, resp.ExpiresIn)
+		}
+	}
+
+	issued := issueJWT(t, handler, "/jwt/issue", `{"claims": {"aud": ["api", "web"], "nbf": 1577880060}, "expires_in": "2m"}`)
+	testCases := []struct {
+		offset      time.Duration
+		target      string
+		token       string
+		description string
+	}{
+		{0, "/jwt", issued.Token, "token not valid before 2020-01-01T12:01:00Z"},
+		{0, "/jwt?leeway=60", issued.Token, ""},
+		{time.Minute, "/jwt", issued.Token, ""},
+		{time.Minute, "/jwt?aud=web", issued.Token, ""},
+		{time.Minute, "/jwt?aud=mobile", issued.Token, "audience does not include 'mobile'"},
+		{time.Minute, "/jwt?iss=other", issued.Token, "issuer 'https://issuer.test' does not match 'other'"},
+		{2 * time.Minute, "/jwt", issued.Token, "token expired at 2020-01-01T12:02:00Z"},
+		{2 * time.Minute, "/jwt?leeway=30s", issued.Token, ""},
+		{time.Minute, "/jwt", issued.Token[:len(issued.Token)-4] + "AAAA", "signature verification failed"},
+		{time.Minute, "/jwt", "not.a-jwt", "token must have 3 segments, got 2"},
+		{time.Minute, "/bearer?mode=jwt", issued.Token, ""},
+	}
+	for _, tc := range testCases {
+		now = jwtTestTime.Add(tc.offset)
+		w := serveJWT(handler, tc.target, tc.token)
+		if tc.description == "" {
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected %s to accept the token at +%v. Status: %d, Challenge: %s", tc.target, tc.offset, w.Code, w.Header().Get("WWW-Authenticate"))
+			}
+			continue
+		}
+		challenge := `Bearer realm="httpbin", error="invalid_token", error_description="` + tc.description + `"`
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != challenge {
+			t.Errorf("Expected %s at +%v to be rejected with %s. Status: %d, Challenge: %s", tc.target, tc.offset, challenge, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	w := serveJWT(handler, "/jwt", "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="httpbin"` {
+		t.Errorf("Expected a challenge without an error for a missing token. Status: %d, Challenge: %s", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestHandleJWTRejectsNone(t *testing.T) {
+	handler := newTestServer(t, jwtTestClock).Handler()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`))
+	w := serveJWT(handler, "/jwt", header+"."+claims+".")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Header().Get("WWW-Authenticate"), "unsupported algorithm 'none'") {
+		t.Errorf("Expected alg none to be rejected. Status: %d, Challenge: %s", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	w = serve(handler, "POST", "/jwt/issue?alg=HS256", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected HS256 without a secret to be rejected, got %d", w.Code)
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	jwks := `{"keys": [{"kty": "OKP", "crv": "Ed25519", "kid": "test",
+		"x": "` + base64.RawURLEncoding.EncodeToString(public) + `",
+		"d": "` + base64.RawURLEncoding.EncodeToString(private.Seed()) + `"}]}`
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(file, []byte(jwks), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := newTestServer(t, WithConfig(&Config{JWKSFile: file}), jwtTestClock).Handler()
+	issued := issueJWT(t, handler, "/jwt/issue?alg=EdDSA", "")
+	if issued.Header["kid"] != "test" {
+		t.Errorf("Expected the token to be signed with the key from the JWKS, got kid %v", issued.Header["kid"])
+	}
+	if w := serveJWT(handler, "/jwt", issued.Token); w.Code != http.StatusOK {
+		t.Errorf("Expected the token to validate. Challenge: %s", w.Header().Get("WWW-Authenticate"))
+	}
+	if w := serve(handler, "POST", "/jwt/issue?alg=RS256", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected RS256 without an RSA key to be rejected, got %d", w.Code)
+	}
+
+	var published jwkSet
+	json.Unmarshal(serve(handler, "GET", "/jwt/jwks", "").Body.Bytes(), &published)
+	if len(published.Keys) != 1 || published.Keys[0].D != "" || published.Keys[0].X == "" {
+		t.Errorf("Expected only the public key to be published, got %+v", published.Keys)
+	}
+}
@@ -0,0 +1,260 @@
+package httpbin
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration
+// histogram
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// streamEndpoints are the streaming handlers reported by
+// httpbin_streams_in_flight
+var streamEndpoints = []string{"drip", "range", "stream", "stream-bytes", "ws-echo", "ws-stream"}
+
+// metrics collects request statistics exported in the Prometheus text
+// format by /metrics
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestLabels]uint64
+	durations map[routeLabels]*histogram
+	bytesOut  map[routeLabels]uint64
+	inFlight  int64
+	streams   map[string]int64
+}
+
+type routeLabels struct {
+	method string
+	route  string
+}
+
+type requestLabels struct {
+	routeLabels
+	code int
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		requests:  make(map[requestLabels]uint64),
+		durations: make(map[routeLabels]*histogram),
+		bytesOut:  make(map[routeLabels]uint64),
+		streams:   make(map[string]int64),
+	}
+	for _, endpoint := range streamEndpoints {
+		m.streams[endpoint] = 0
+	}
+	return m
+}
+
+func (m *metrics) observe(labels routeLabels, code int, bytes int64, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestLabels{labels, code}]++
+	m.bytesOut[labels] += uint64(bytes)
+
+	h, ok := m.durations[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		m.durations[labels] = h
+	}
+	secs := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if secs <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+func (m *metrics) addInFlight(delta int64) {
+	m.mu.Lock()
+	m.inFlight += delta
+	m.mu.Unlock()
+}
+
+func (m *metrics) addStream(endpoint string, delta int64) {
+	m.mu.Lock()
+	m.streams[endpoint] += delta
+	m.mu.Unlock()
+}
+
+// writeTo renders the metrics in the Prometheus text exposition format
+func (m *metrics) writeTo(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(buf, "httpbin_requests_total", "counter", "Requests served, by method, route template and status code.")
+	requests := make([]requestLabels, 0, len(m.requests))
+	for labels := range m.requests {
+		requests = append(requests, labels)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].routeLabels != requests[j].routeLabels {
+			return requests[i].routeLabels.less(requests[j].routeLabels)
+		}
+		return requests[i].code < requests[j].code
+	})
+	for _, labels := range requests {
+		fmt.Fprintf(buf, "httpbin_requests_total{%s,code=\"%d\"} %d\n", labels.routeLabels, labels.code, m.requests[labels])
+	}
+
+	routes := make([]routeLabels, 0, len(m.durations))
+	for labels := range m.durations {
+		routes = append(routes, labels)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].less(routes[j]) })
+
+	writeHeader(buf, "httpbin_request_duration_seconds", "histogram", "Time to serve a request, by method and route template.")
+	for _, labels := range routes {
+		h := m.durations[labels]
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(buf, "httpbin_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(buf, "httpbin_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(buf, "httpbin_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(buf, "httpbin_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	writeHeader(buf, "httpbin_response_bytes_total", "counter", "Response body bytes written, by method and route template.")
+	for _, labels := range routes {
+		fmt.Fprintf(buf, "httpbin_response_bytes_total{%s} %d\n", labels, m.bytesOut[labels])
+	}
+
+	writeHeader(buf, "httpbin_requests_in_flight", "gauge", "Requests currently being served.")
+	fmt.Fprintf(buf, "httpbin_requests_in_flight %d\n", m.inFlight)
+
+	writeHeader(buf, "httpbin_streams_in_flight", "gauge", "Streaming responses currently being written, by endpoint.")
+	for _, endpoint := range streamEndpoints {
+		fmt.Fprintf(buf, "httpbin_streams_in_flight{endpoint=%q} %d\n", endpoint, m.streams[endpoint])
+	}
+}
+
+func (l routeLabels) less(o routeLabels) bool {
+	if l.route != o.route {
+		return l.route < o.route
+	}
+	return l.method < o.method
+}
+
+func (l routeLabels) String() string {
+	return fmt.Sprintf("method=\"%s\",route=\"%s\"", escapeLabel(l.method), escapeLabel(l.route))
+}
+
+func writeHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// metricsMethod limits the method label to standard methods so clients
+// cannot create arbitrarily many series
+func metricsMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE":
+		return method
+	}
+	return "OTHER"
+}
+
+// collectMetrics records every request against the template of the route
+// it matches, e.g. /status/{codes}, rather than its raw path
+func (s *Server) collectMetrics(next http.Handler) http.Handler {
+	if s.metrics == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		var match mux.RouteMatch
+		if s.root.Match(r, &match) && match.Route != nil {
+			// routes without a path template are the admin API's mocks
+			route = "mock"
+			if tpl, err := match.Route.GetPathTemplate(); err == nil {
+				route = stripPatterns(tpl)
+			}
+		}
+
+		s.metrics.addInFlight(1)
+		defer s.metrics.addInFlight(-1)
+
+		start := time.Now()
+		rw := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		labels := routeLabels{method: metricsMethod(r.Method), route: route}
+		s.metrics.observe(labels, rw.statusCode(), rw.bytes, time.Since(start))
+	})
+}
+
+// stripPatterns removes variable patterns from a route template so
+// /bytes/{n:[0-9]+} is reported as /bytes/{n}
+func stripPatterns(tpl string) string {
+	var b strings.Builder
+	depth := 0
+	skip := false
+	for _, c := range tpl {
+		switch {
+		case c == '{':
+			depth++
+			if depth > 1 {
+				continue
+			}
+		case c == '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			skip = false
+		case c == ':' && depth == 1:
+			skip = true
+		}
+		if !skip {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// trackStream counts a streaming response as in flight until the returned
+// function is called
+func (s *Server) trackStream(endpoint string) func() {
+	if s.metrics == nil {
+		return func() {}
+	}
+	s.metrics.addStream(endpoint, 1)
+	return func() { s.metrics.addStream(endpoint, -1) }
+}
+
+func (s *Server) handleMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if s.metrics != nil {
+			s.metrics.writeTo(&buf)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
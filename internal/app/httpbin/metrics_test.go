@@ -0,0 +1,71 @@
+package httpbin
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	server := newTestServer(t, WithConfig(&Config{MaxBytes: DefaultMaxBytes}), WithPathPrefix("/httpbin"))
+	handler := server.Handler()
+
+	for _, target := range []string{"/httpbin/status/418", "/httpbin/status/418", "/httpbin/bytes/16", "/nope"} {
+		serve(handler, "GET", target, "")
+	}
+
+	w := serve(handler, "GET", "/httpbin/metrics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got: %d", w.Code)
+	}
+
+	body := w.Body.String()
+	expected := []string{
+		`httpbin_requests_total{method="GET",route="/httpbin/status/{codes}",code="418"} 2`,
+		`httpbin_requests_total{method="GET",route="unmatched",code="404"} 1`,
+		`httpbin_request_duration_seconds_count{method="GET",route="/httpbin/status/{codes}"} 2`,
+		`httpbin_request_duration_seconds_bucket{method="GET",route="/httpbin/status/{codes}",le="+Inf"} 2`,
+		`httpbin_response_bytes_total{method="GET",route="/httpbin/bytes/{n}"} 16`,
+		`httpbin_requests_in_flight 1`,
+		`httpbin_streams_in_flight{endpoint="drip"} 0`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestStripPatterns(t *testing.T) {
+	testCases := map[string]string{
+		"/bytes/{n:[0-9]+}":         "/bytes/{n}",
+		"/status/{codes}":           "/status/{codes}",
+		"/links/{n:[0-9]{1,3}}/{o}": "/links/{n}/{o}",
+	}
+	for tpl, expected := range testCases {
+		if val := stripPatterns(tpl); val != expected {
+			t.Errorf("Expected %s to become %s, got: %s", tpl, expected, val)
+		}
+	}
+}
+
+func TestTrackStream(t *testing.T) {
+	server := &Server{metrics: newMetrics()}
+	done := server.trackStream("drip")
+	if n := server.metrics.streams["drip"]; n != 1 {
+		t.Errorf("Expected 1 drip stream in flight, got: %d", n)
+	}
+	done()
+	if n := server.metrics.streams["drip"]; n != 0 {
+		t.Errorf("Expected 0 drip streams in flight, got: %d", n)
+	}
+}
+
+func TestHandleMetrics_Disabled(t *testing.T) {
+	config := DefaultConfig()
+	config.RouteGroups = []string{"http"}
+	server := newTestServer(t, WithConfig(config))
+	if server.metrics != nil {
+		t.Errorf("Expected metrics to be disabled")
+	}
+}
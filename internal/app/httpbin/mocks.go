@@ -0,0 +1,478 @@
+package httpbin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxMockJournal caps the number of requests served by mocks that are
+// kept for verification
+const maxMockJournal = 1000
+
+// mock is an endpoint registered at runtime through the admin API
+type mock struct {
+	ID       string       `json:"id"`
+	Created  time.Time    `json:"created"`
+	Request  mockRequest  `json:"request"`
+	Response mockResponse `json:"response"`
+	// Times is the number of requests the mock answers before it stops
+	// matching, zero for no limit
+	Times int `json:"times,omitempty"`
+	Calls int `json:"calls"`
+}
+
+// mockRequest matches incoming requests
+type mockRequest struct {
+	Method  string                   `json:"method,omitempty"`
+	Path    string                   `json:"path"`
+	Headers map[string]*valueMatcher `json:"headers,omitempty"`
+	Query   map[string]*valueMatcher `json:"query,omitempty"`
+	Body    *valueMatcher            `json:"body,omitempty"`
+
+	route *mux.Route
+}
+
+// mockResponse is returned by a mock
+type mockResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	JSON    interface{}       `json:"json,omitempty"`
+	Delay   Duration          `json:"delay,omitempty"`
+	// Template renders Body and Headers as response templates
+	Template bool `json:"template,omitempty"`
+
+	body    *template.Template
+	headers map[string]*template.Template
+}
+
+// valueMatcher matches a header, query parameter or body. It is read from
+// JSON either as a plain string, meaning equals, or as an object.
+type valueMatcher struct {
+	Equals   *string     `json:"equals,omitempty"`
+	Contains string      `json:"contains,omitempty"`
+	Matches  string      `json:"matches,omitempty"`
+	JSON     interface{} `json:"json,omitempty"`
+	Absent   bool        `json:"absent,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// mockCall is a request served by a mock
+type mockCall struct {
+	MockID   string      `json:"mock_id"`
+	Received time.Time   `json:"received"`
+	Method   string      `json:"method"`
+	Path     string      `json:"path"`
+	Query    string      `json:"query"`
+	Headers  http.Header `json:"headers"`
+	Body     string      `json:"body"`
+}
+
+// UnmarshalJSON accepts a string as shorthand for {"equals": "..."}
+func (m *valueMatcher) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		m.Equals = &s
+		return nil
+	}
+	type plain valueMatcher
+	return json.Unmarshal(b, (*plain)(m))
+}
+
+func (m *valueMatcher) compile() error {
+	if m.Matches == "" {
+		return nil
+	}
+	re, err := regexp.Compile(m.Matches)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", m.Matches, err)
+	}
+	m.re = re
+	return nil
+}
+
+func (m *valueMatcher) match(val string, present bool) bool {
+	if m.Absent {
+		return !present
+	}
+	if !present {
+		return false
+	}
+	if m.Equals != nil && val != *m.Equals {
+		return false
+	}
+	if m.Contains != "" && !strings.Contains(val, m.Contains) {
+		return false
+	}
+	if m.re != nil && !m.re.MatchString(val) {
+		return false
+	}
+	if m.JSON != nil {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(val), &decoded); err != nil {
+			return false
+		}
+		expected, _ := json.Marshal(m.JSON)
+		var normalized interface{}
+		json.Unmarshal(expected, &normalized)
+		if !reflect.DeepEqual(decoded, normalized) {
+			return false
+		}
+	}
+	return true
+}
+
+// compile validates the matcher and builds the route its path is matched
+// with, prefix is the server's base path. An empty path matches any path.
+func (m *mockRequest) compile(prefix string) error {
+	if m.Path != "" {
+		if !strings.HasPrefix(m.Path, "/") {
+			return fmt.Errorf("path must start with /")
+		}
+		route := mux.NewRouter().NewRoute().Path(prefix + m.Path)
+		if err := route.GetError(); err != nil {
+			return fmt.Errorf("invalid path %q: %v", m.Path, err)
+		}
+		m.route = route
+	}
+
+	matchers := []*valueMatcher{m.Body}
+	for _, vm := range m.Headers {
+		matchers = append(matchers, vm)
+	}
+	for _, vm := range m.Query {
+		matchers = append(matchers, vm)
+	}
+	for _, vm := range matchers {
+		if vm == nil {
+			continue
+		}
+		if err := vm.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match reports whether r matches, body is only called when the body has
+// to be inspected
+func (m *mockRequest) match(r *http.Request, body func() string) bool {
+	if m.Method != "" && !strings.EqualFold(m.Method, r.Method) {
+		return false
+	}
+	var match mux.RouteMatch
+	if m.route != nil && !m.route.Match(r, &match) {
+		return false
+	}
+	for key, vm := range m.Headers {
+		vals, ok := r.Header[http.CanonicalHeaderKey(key)]
+		if !vm.match(strings.Join(vals, ","), ok) {
+			return false
+		}
+	}
+	query := r.URL.Query()
+	for key, vm := range m.Query {
+		vals, ok := query[key]
+		if !vm.match(strings.Join(vals, ","), ok) {
+			return false
+		}
+	}
+	if m.Body != nil && !m.Body.match(body(), true) {
+		return false
+	}
+	return true
+}
+
+// request rebuilds the request a call was made with so it can be matched
+// again when verifying
+func (c *mockCall) request() *http.Request {
+	return &http.Request{
+		Method: c.Method,
+		URL:    &url.URL{Path: c.Path, RawQuery: c.Query},
+		Header: c.Headers,
+	}
+}
+
+// mockStore holds the registered mocks, newest first, and the journal of
+// requests they served
+type mockStore struct {
+	mu      sync.Mutex
+	mocks   []*mock
+	journal []*mockCall
+}
+
+func (ms *mockStore) add(m *mock) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.mocks = append([]*mock{m}, ms.mocks...)
+}
+
+func (ms *mockStore) get(id string) *mock {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, m := range ms.mocks {
+		if m.ID == id {
+			copied := *m
+			return &copied
+		}
+	}
+	return nil
+}
+
+func (ms *mockStore) list() []mock {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	mocks := make([]mock, len(ms.mocks))
+	for i, m := range ms.mocks {
+		mocks[i] = *m
+	}
+	return mocks
+}
+
+func (ms *mockStore) remove(id string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for i, m := range ms.mocks {
+		if m.ID == id {
+			ms.mocks = append(ms.mocks[:i], ms.mocks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (ms *mockStore) reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.mocks = nil
+	ms.journal = nil
+}
+
+func (ms *mockStore) calls() []*mockCall {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]*mockCall{}, ms.journal...)
+}
+
+// find returns the newest mock matching the request that has not used up
+// its times
+func (ms *mockStore) find(r *http.Request, body func() string) *mock {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.findLocked(r, body)
+}
+
+func (ms *mockStore) findLocked(r *http.Request, body func() string) *mock {
+	for _, m := range ms.mocks {
+		if m.Times > 0 && m.Calls >= m.Times {
+			continue
+		}
+		if m.Request.match(r, body) {
+			return m
+		}
+	}
+	return nil
+}
+
+// serve finds the mock for a request, counts the call and records it in
+// the journal
+func (ms *mockStore) serve(r *http.Request, body []byte, received time.Time) *mock {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	m := ms.findLocked(r, func() string { return string(body) })
+	if m == nil {
+		return nil
+	}
+	m.Calls++
+	if len(ms.journal) >= maxMockJournal {
+		ms.journal = ms.journal[1:]
+	}
+	ms.journal = append(ms.journal, &mockCall{
+		MockID:   m.ID,
+		Received: received,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Headers:  r.Header,
+		Body:     jsonSafe(body, r.Header.Get("Content-Type")),
+	})
+	copied := *m
+	return &copied
+}
+
+// skipMocksKey marks requests that are routed again without mocks
+type skipMocksKey struct{}
+
+// matchMock is a mux.MatcherFunc selecting requests that a mock answers
+func (s *Server) matchMock(r *http.Request, rm *mux.RouteMatch) bool {
+	if r.Context().Value(skipMocksKey{}) != nil {
+		return false
+	}
+	// matching runs before limitBody, so only the first MaxBodySize bytes
+	// are read and a larger body matches no mock
+	var body []byte
+	read, tooLarge := false, false
+	m := s.mocks.find(r, func() string {
+		if !read {
+			body, tooLarge = peekBodyLimit(r, s.cfg().MaxBodySize)
+			read = true
+		}
+		return string(body)
+	})
+	return m != nil && !tooLarge
+}
+
+// peekBody reads the request body and replaces it with a copy
+func peekBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+// peekBodyLimit reads up to limit bytes of the request body and puts them
+// back in front of the rest of it. It reports whether the body is larger
+// than the limit; a limit <= 0 reads the whole body.
+func peekBodyLimit(r *http.Request, limit int64) ([]byte, bool) {
+	if limit <= 0 {
+		body, _ := peekBody(r)
+		return body, false
+	}
+	if r.Body == nil {
+		return nil, false
+	}
+	if r.ContentLength > limit {
+		return nil, true
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body, int64(len(body)) > limit
+}
+
+func (s *Server) handleMock() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := peekBody(r)
+		if err != nil {
+			writeRequestError(w, &bodyError{err})
+			return
+		}
+		m := s.mocks.serve(r, body, s.now().UTC())
+		if m == nil {
+			// a concurrent request used up the mock after it matched, so
+			// serve the route the request would otherwise have hit
+			s.root.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), skipMocksKey{}, true)))
+			return
+		}
+
+		resp := m.Response
+		if delay := time.Duration(resp.Delay); delay > 0 {
+			if max := time.Duration(s.cfg().MaxDelay); delay > max {
+				delay = max
+			}
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if resp.Template {
+			var match mux.RouteMatch
+			m.Request.route.Match(r, &match)
+			data, err := newTemplateData(r, match.Vars)
+			if err != nil {
+				writeRequestError(w, err)
+				return
+			}
+			writeTemplate(w, r, resp.status(), "", data, resp.body, resp.headers)
+			return
+		}
+
+		respBody := []byte(resp.Body)
+		if resp.JSON != nil {
+			var err error
+			if respBody, err = json.Marshal(resp.JSON); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+		}
+		for key, val := range resp.Headers {
+			w.Header().Set(key, val)
+		}
+		w.WriteHeader(resp.status())
+		w.Write(respBody)
+	}
+}
+
+func (s *Server) newMock(body []byte) (*mock, error) {
+	m := &mock{}
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, fmt.Errorf("invalid mock: %v", err)
+	}
+	if m.Request.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if err := m.Request.compile(s.basePath()); err != nil {
+		return nil, err
+	}
+	if m.Response.Status != 0 && (m.Response.Status < 100 || m.Response.Status > 999) {
+		return nil, fmt.Errorf("invalid response status: %d", m.Response.Status)
+	}
+	if m.Response.Template {
+		if err := s.compileMockTemplates(&m.Response); err != nil {
+			return nil, err
+		}
+	}
+	if m.Times < 0 {
+		return nil, fmt.Errorf("times must not be negative")
+	}
+	m.ID = uuid.New().String()
+	m.Created = s.now().UTC()
+	m.Calls = 0
+	return m, nil
+}
+
+func (s *Server) compileMockTemplates(resp *mockResponse) error {
+	var err error
+	if resp.body, err = s.parseResponseTemplate("body", resp.Body); err != nil {
+		return fmt.Errorf("invalid body template: %v", err)
+	}
+	resp.headers = make(map[string]*template.Template, len(resp.Headers))
+	for name, val := range resp.Headers {
+		if resp.headers[name], err = s.parseResponseTemplate(name, val); err != nil {
+			return fmt.Errorf("invalid template for header %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (r *mockResponse) status() int {
+	if r.Status == 0 {
+		return http.StatusOK
+	}
+	return r.Status
+}
@@ -0,0 +1,866 @@
+package httpbin
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxOAuthGrants caps the number of codes, refresh tokens, device codes
+	// and revoked tokens of each kind that are remembered
+	maxOAuthGrants = 10000
+
+	oauthCodeTTL         = 10 * time.Minute
+	oauthDeviceCodeTTL   = 10 * time.Minute
+	oauthRefreshTokenTTL = 24 * time.Hour
+	oauthDeviceInterval  = 1
+
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// OAuthUser is a user of the mock OAuth 2.0 / OpenID Connect provider.
+// Claims are added to ID tokens and returned from userinfo.
+type OAuthUser struct {
+	Username string                 `json:"username"`
+	Password string                 `json:"password"`
+	Claims   map[string]interface{} `json:"claims"`
+}
+
+// OAuthClient is a client of the mock OAuth 2.0 / OpenID Connect provider.
+// Clients without a secret are public and must use PKCE. Empty
+// RedirectURIs allows any redirect URI and empty Scopes any scope.
+type OAuthClient struct {
+	ID             string   `json:"client_id"`
+	Secret         string   `json:"client_secret"`
+	RedirectURIs   []string `json:"redirect_uris"`
+	Scopes         []string `json:"scopes"`
+	AccessTokenTTL Duration `json:"access_token_ttl"`
+}
+
+var defaultOAuthUsers = []OAuthUser{{
+	Username: "user",
+	Password: "passwd",
+	Claims:   map[string]interface{}{"name": "Test User", "email": "user@example.com", "email_verified": true},
+}}
+
+var defaultOAuthClients = []OAuthClient{{ID: "httpbin", Secret: "secret"}, {ID: "httpbin-public"}}
+
+// oauthGrant is what a code, refresh token or device code was issued for
+type oauthGrant struct {
+	client          string
+	user            string
+	scope           string
+	nonce           string
+	redirectURI     string
+	challenge       string
+	challengeMethod string
+	authTime        time.Time
+	expires         time.Time
+}
+
+type oauthDevice struct {
+	grant    *oauthGrant
+	userCode string
+	approved bool
+	denied   bool
+}
+
+// oauthStore holds the state of the mock OAuth provider. Each kind of
+// state is capped at maxOAuthGrants entries: when full, expired entries are
+// pruned and then the entry expiring first is evicted.
+type oauthStore struct {
+	mu        sync.Mutex
+	codes     map[string]*oauthGrant
+	refresh   map[string]*oauthGrant
+	devices   map[string]*oauthDevice
+	userCodes map[string]string
+	// revoked maps the jti of revoked access tokens to when they expire
+	revoked map[string]time.Time
+}
+
+func (o *oauthStore) init() {
+	if o.codes == nil {
+		o.codes = make(map[string]*oauthGrant)
+		o.refresh = make(map[string]*oauthGrant)
+		o.devices = make(map[string]*oauthDevice)
+		o.userCodes = make(map[string]string)
+		o.revoked = make(map[string]time.Time)
+	}
+}
+
+// makeRoom removes the expired entries of m once it is full, then the entry
+// expiring first if it is still full
+func makeRoom[V any](m map[string]V, expires func(V) time.Time, now time.Time) {
+	if len(m) < maxOAuthGrants {
+		return
+	}
+	var first string
+	var firstExpires time.Time
+	for key, val := range m {
+		exp := expires(val)
+		if !now.Before(exp) {
+			delete(m, key)
+			continue
+		}
+		if first == "" || exp.Before(firstExpires) {
+			first, firstExpires = key, exp
+		}
+	}
+	if len(m) >= maxOAuthGrants {
+		delete(m, first)
+	}
+}
+
+func grantExpires(g *oauthGrant) time.Time { return g.expires }
+
+func (o *oauthStore) addCode(code string, g *oauthGrant, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.init()
+	makeRoom(o.codes, grantExpires, now)
+	o.codes[code] = g
+}
+
+// takeCode returns the grant of an authorization code issued to client,
+// which can only be used once. Codes of other clients are left untouched.
+func (o *oauthStore) takeCode(code, client string) *oauthGrant {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	g := o.codes[code]
+	if g == nil || g.client != client {
+		return nil
+	}
+	delete(o.codes, code)
+	return g
+}
+
+func (o *oauthStore) addRefresh(token string, g *oauthGrant, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.init()
+	makeRoom(o.refresh, grantExpires, now)
+	o.refresh[token] = g
+}
+
+// refreshGrant returns the grant of a refresh token
+func (o *oauthStore) refreshGrant(token string) *oauthGrant {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.refresh[token]
+}
+
+// takeRefresh returns and removes the grant of a refresh token issued to
+// client, since refresh tokens are rotated on use. Tokens of other clients
+// are left untouched.
+func (o *oauthStore) takeRefresh(token, client string) *oauthGrant {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	g := o.refresh[token]
+	if g == nil || g.client != client {
+		return nil
+	}
+	delete(o.refresh, token)
+	return g
+}
+
+func (o *oauthStore) addDevice(deviceCode string, d *oauthDevice, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.init()
+	makeRoom(o.devices, func(d *oauthDevice) time.Time { return d.grant.expires }, now)
+	if len(o.userCodes) > len(o.devices) {
+		// forget the user codes of evicted devices
+		for userCode, deviceCode := range o.userCodes {
+			if _, ok := o.devices[deviceCode]; !ok {
+				delete(o.userCodes, userCode)
+			}
+		}
+	}
+	o.devices[deviceCode] = d
+	o.userCodes[d.userCode] = deviceCode
+}
+
+// verifyDevice approves or denies the device authorization of a user code
+func (o *oauthStore) verifyDevice(userCode, user string, approve bool, now time.Time) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	d, ok := o.devices[o.userCodes[userCode]]
+	if !ok || !now.Before(d.grant.expires) || d.approved || d.denied {
+		return false
+	}
+	d.grant.user = user
+	d.grant.authTime = now
+	d.approved = approve
+	d.denied = !approve
+	return true
+}
+
+// pollDevice returns the device authorization of a device code issued to
+// the client, removing it once it has been approved
+func (o *oauthStore) pollDevice(deviceCode, client string) (oauthDevice, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	d, ok := o.devices[deviceCode]
+	if !ok || d.grant.client != client {
+		return oauthDevice{}, false
+	}
+	if d.approved {
+		delete(o.devices, deviceCode)
+		delete(o.userCodes, d.userCode)
+	}
+	return *d, true
+}
+
+// revoke revokes the access token with the given jti until it expires
+func (o *oauthStore) revoke(jti string, expires, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.init()
+	makeRoom(o.revoked, func(exp time.Time) time.Time { return exp }, now)
+	o.revoked[jti] = expires
+}
+
+func (o *oauthStore) isRevoked(jti string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.revoked[jti]
+	return ok
+}
+
+// oauthError is an RFC 6749 error response
+type oauthError struct {
+	status      int
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func newOAuthError(status int, code, format string, v ...interface{}) *oauthError {
+	return &oauthError{status: status, Code: code, Description: fmt.Sprintf(format, v...)}
+}
+
+func writeOAuthError(w http.ResponseWriter, err *oauthError) {
+	if err.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="httpbin"`)
+	}
+	writeJSON(w, err.status, err)
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type oauthDeviceResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// handleOpenIDConfiguration serves the OpenID Connect discovery document
+func (s *Server) handleOpenIDConfiguration() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		issuer := s.oauthIssuer(r)
+		base := getBaseURL(r) + s.basePath()
+		alg, _ := s.oauthSigningAlg()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                issuer,
+			"authorization_endpoint":                base + "/oauth/authorize",
+			"token_endpoint":                        base + "/oauth/token",
+			"device_authorization_endpoint":         base + "/oauth/device_authorization",
+			"introspection_endpoint":                base + "/oauth/introspect",
+			"revocation_endpoint":                   base + "/oauth/revoke",
+			"userinfo_endpoint":                     base + "/oauth/userinfo",
+			"jwks_uri":                              base + "/.well-known/jwks.json",
+			"response_types_supported":              []string{"code"},
+			"grant_types_supported":                 []string{"authorization_code", "client_credentials", "refresh_token", deviceCodeGrantType},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{alg},
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+			"code_challenge_methods_supported":      []string{"plain", "S256"},
+			"scopes_supported":                      []string{"openid", "profile", "email", "offline_access"},
+		})
+	}
+}
+
+// handleOAuthAuthorize issues an authorization code to the redirect URI.
+// Consent is implied: the code is for the user whose Basic credentials
+// were sent, the user named by ?login_hint or else the first user.
+func (s *Server) handleOAuthAuthorize() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		client := s.oauthClientByID(query.Get("client_id"))
+		if client == nil {
+			writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_request", "unknown client_id '%s'", query.Get("client_id")))
+			return
+		}
+		redirectURI := query.Get("redirect_uri")
+		target := redirectURI
+		if target == "" && len(client.RedirectURIs) == 1 {
+			target = client.RedirectURIs[0]
+		}
+		if target == "" || (len(client.RedirectURIs) > 0 && !contains(client.RedirectURIs, target)) {
+			writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_request", "redirect_uri '%s' is not registered", target))
+			return
+		}
+		redirect, err := url.Parse(target)
+		if err != nil || !redirect.IsAbs() {
+			writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_request", "redirect_uri '%s' must be an absolute URL", target))
+			return
+		}
+
+		params := redirect.Query()
+		if state := query.Get("state"); state != "" {
+			params.Set("state", state)
+		}
+		fail := func(code, description string) {
+			params.Set("error", code)
+			params.Set("error_description", description)
+			redirect.RawQuery = params.Encode()
+			http.Redirect(w, r, redirect.String(), http.StatusFound)
+		}
+
+		if responseType := query.Get("response_type"); responseType != "code" {
+			fail("unsupported_response_type", fmt.Sprintf("response_type '%s' is not supported", responseType))
+			return
+		}
+		scope, oerr := checkOAuthScope(client, query.Get("scope"))
+		if oerr != nil {
+			fail(oerr.Code, oerr.Description)
+			return
+		}
+		challenge, method := query.Get("code_challenge"), query.Get("code_challenge_method")
+		if challenge != "" && method == "" {
+			method = "plain"
+		}
+		if method != "" && method != "plain" && method != "S256" {
+			fail("invalid_request", fmt.Sprintf("code_challenge_method '%s' is not supported", method))
+			return
+		}
+		if client.Secret == "" && challenge == "" {
+			fail("invalid_request", "public clients must use PKCE")
+			return
+		}
+		user, ok := s.oauthUser(r)
+		if !ok {
+			fail("access_denied", "invalid user credentials")
+			return
+		}
+
+		now := s.now()
+		code := randomHex(32)
+		s.oauth.addCode(code, &oauthGrant{
+			client:          client.ID,
+			user:            user.Username,
+			scope:           scope,
+			nonce:           query.Get("nonce"),
+			redirectURI:     redirectURI,
+			challenge:       challenge,
+			challengeMethod: method,
+			authTime:        now,
+			expires:         now.Add(oauthCodeTTL),
+		}, now)
+		params.Set("code", code)
+		redirect.RawQuery = params.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	}
+}
+
+// handleOAuthToken exchanges authorization codes, refresh tokens, device
+// codes and client credentials for tokens
+func (s *Server) handleOAuthToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		client, oerr := s.authenticateOAuthClient(r)
+		if oerr != nil {
+			writeOAuthError(w, oerr)
+			return
+		}
+
+		now := s.now()
+		var grant *oauthGrant
+		refresh := true
+		switch grantType := r.PostForm.Get("grant_type"); grantType {
+		case "authorization_code":
+			grant = s.oauth.takeCode(r.PostForm.Get("code"), client.ID)
+			if grant == nil || !now.Before(grant.expires) {
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_grant", "invalid, expired or used authorization code"))
+				return
+			}
+			if r.PostForm.Get("redirect_uri") != grant.redirectURI {
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request"))
+				return
+			}
+			if !checkPKCE(grant, r.PostForm.Get("code_verifier")) {
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code_challenge"))
+				return
+			}
+		case "refresh_token":
+			grant = s.oauth.takeRefresh(r.PostForm.Get("refresh_token"), client.ID)
+			if grant == nil || !now.Before(grant.expires) {
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_grant", "invalid, expired or used refresh token"))
+				return
+			}
+			if scope := r.PostForm.Get("scope"); scope != "" {
+				granted := strings.Fields(grant.scope)
+				for _, sc := range strings.Fields(scope) {
+					if !contains(granted, sc) {
+						writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_scope", "scope '%s' was not granted", sc))
+						return
+					}
+				}
+				narrowed := *grant
+				narrowed.scope = scope
+				grant = &narrowed
+			}
+		case "client_credentials":
+			if client.Secret == "" {
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "unauthorized_client", "public clients cannot use client_credentials"))
+				return
+			}
+			scope, oerr := checkOAuthScope(client, r.PostForm.Get("scope"))
+			if oerr != nil {
+				writeOAuthError(w, oerr)
+				return
+			}
+			grant = &oauthGrant{client: client.ID, scope: scope}
+			refresh = false
+		case deviceCodeGrantType:
+			d, ok := s.oauth.pollDevice(r.PostForm.Get("device_code"), client.ID)
+			switch {
+			case !ok:
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_grant", "invalid device_code"))
+				return
+			case d.denied:
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "access_denied", "the user denied the authorization"))
+				return
+			case !now.Before(d.grant.expires):
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "expired_token", "the device_code has expired"))
+				return
+			case !d.approved:
+				writeOAuthError(w, newOAuthError(http.StatusBadRequest, "authorization_pending", "the user has not yet approved the authorization"))
+				return
+			}
+			grant = d.grant
+		default:
+			writeOAuthError(w, newOAuthError(http.StatusBadRequest, "unsupported_grant_type", "grant_type '%s' is not supported", grantType))
+			return
+		}
+
+		resp, err := s.issueOAuthTokens(r, client, grant, refresh)
+		if err != nil {
+			writeOAuthError(w, newOAuthError(http.StatusInternalServerError, "server_error", "%v", err))
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// issueOAuthTokens mints an access token for the grant and, for grants of
+// a user, a refresh token and, with the openid scope, an ID token
+func (s *Server) issueOAuthTokens(r *http.Request, client *OAuthClient, g *oauthGrant, refresh bool) (oauthTokenResponse, error) {
+	alg, err := s.oauthSigningAlg()
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	now := s.now()
+	ttl := time.Duration(client.AccessTokenTTL)
+	if ttl <= 0 {
+		ttl = defaultJWTExpiry
+	}
+	issuer := s.oauthIssuer(r)
+	sub := g.user
+	if sub == "" {
+		sub = client.ID
+	}
+	aud := s.cfg().JWTAudience
+	if aud == "" {
+		aud = client.ID
+	}
+
+	claims := map[string]interface{}{
+		"iss":       issuer,
+		"sub":       sub,
+		"aud":       aud,
+		"client_id": client.ID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+		"jti":       randomHex(16),
+	}
+	if g.scope != "" {
+		claims["scope"] = g.scope
+	}
+	accessToken, err := s.signJWT(map[string]interface{}{"alg": alg, "typ": "at+jwt"}, claims, "")
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	resp := oauthTokenResponse{AccessToken: accessToken, TokenType: "Bearer", ExpiresIn: int64(ttl / time.Second), Scope: g.scope}
+
+	if refresh {
+		resp.RefreshToken = randomHex(32)
+		refreshGrant := *g
+		refreshGrant.expires = now.Add(oauthRefreshTokenTTL)
+		s.oauth.addRefresh(resp.RefreshToken, &refreshGrant, now)
+	}
+
+	if g.user != "" && contains(strings.Fields(g.scope), "openid") {
+		idClaims := map[string]interface{}{}
+		if user := s.oauthUserByName(g.user); user != nil {
+			for name, val := range user.Claims {
+				idClaims[name] = val
+			}
+		}
+		idClaims["iss"] = issuer
+		idClaims["sub"] = g.user
+		idClaims["aud"] = client.ID
+		idClaims["iat"] = now.Unix()
+		idClaims["exp"] = now.Add(ttl).Unix()
+		idClaims["auth_time"] = g.authTime.Unix()
+		if g.nonce != "" {
+			idClaims["nonce"] = g.nonce
+		}
+		resp.IDToken, err = s.signJWT(map[string]interface{}{"alg": alg, "typ": "JWT"}, idClaims, "")
+		if err != nil {
+			return oauthTokenResponse{}, err
+		}
+	}
+	return resp, nil
+}
+
+// handleOAuthDeviceAuthorization starts a device authorization, which the
+// user approves at /oauth/device
+func (s *Server) handleOAuthDeviceAuthorization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		client, oerr := s.authenticateOAuthClient(r)
+		if oerr != nil {
+			writeOAuthError(w, oerr)
+			return
+		}
+		scope, oerr := checkOAuthScope(client, r.PostForm.Get("scope"))
+		if oerr != nil {
+			writeOAuthError(w, oerr)
+			return
+		}
+
+		deviceCode, userCode := randomHex(32), randomUserCode()
+		now := s.now()
+		s.oauth.addDevice(deviceCode, &oauthDevice{
+			grant:    &oauthGrant{client: client.ID, scope: scope, expires: now.Add(oauthDeviceCodeTTL)},
+			userCode: userCode,
+		}, now)
+
+		verificationURI := getBaseURL(r) + s.path("/oauth/device")
+		writeJSON(w, http.StatusOK, oauthDeviceResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                userCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+			ExpiresIn:               int64(oauthDeviceCodeTTL / time.Second),
+			Interval:                oauthDeviceInterval,
+		})
+	}
+}
+
+// handleOAuthDevice approves the device authorization of ?user_code for
+// the user picked as by /oauth/authorize, or denies it with ?action=deny
+func (s *Server) handleOAuthDevice() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		userCode := strings.ToUpper(r.Form.Get("user_code"))
+		user, ok := s.oauthUser(r)
+		if !ok {
+			writeOAuthError(w, newOAuthError(http.StatusUnauthorized, "access_denied", "invalid user credentials"))
+			return
+		}
+		approve := r.Form.Get("action") != "deny"
+		if !s.oauth.verifyDevice(userCode, user.Username, approve, s.now()) {
+			writeOAuthError(w, newOAuthError(http.StatusBadRequest, "invalid_request", "unknown, expired or used user_code '%s'", userCode))
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"user_code": userCode, "user": user.Username, "approved": approve})
+	}
+}
+
+// handleOAuthIntrospect describes a token as in RFC 7662
+func (s *Server) handleOAuthIntrospect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, oerr := s.authenticateOAuthClient(r); oerr != nil {
+			writeOAuthError(w, oerr)
+			return
+		}
+
+		token := r.PostForm.Get("token")
+		if g := s.oauth.refreshGrant(token); g != nil && s.now().Before(g.expires) {
+			resp := map[string]interface{}{
+				"active":     true,
+				"token_type": "refresh_token",
+				"client_id":  g.client,
+				"exp":        g.expires.Unix(),
+			}
+			if g.scope != "" {
+				resp["scope"] = g.scope
+			}
+			if g.user != "" {
+				resp["sub"] = g.user
+				resp["username"] = g.user
+			}
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+
+		claims, err := s.verifyOAuthAccessToken(r, token)
+		if err != nil {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"active": false})
+			return
+		}
+		resp := map[string]interface{}{"active": true, "token_type": "Bearer"}
+		for name, val := range claims {
+			resp[name] = val
+		}
+		if s.oauthUserByName(fmt.Sprint(claims["sub"])) != nil {
+			resp["username"] = claims["sub"]
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// handleOAuthRevoke revokes a refresh or access token of the client as in
+// RFC 7009, answering 200 whether or not the token was valid
+func (s *Server) handleOAuthRevoke() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, oerr := s.authenticateOAuthClient(r)
+		if oerr != nil {
+			writeOAuthError(w, oerr)
+			return
+		}
+
+		token := r.PostForm.Get("token")
+		if g := s.oauth.refreshGrant(token); g != nil {
+			s.oauth.takeRefresh(token, client.ID)
+		} else if claims, err := s.verifyOAuthAccessToken(r, token); err == nil && claims["client_id"] == client.ID {
+			exp, _ := claims["exp"].(float64)
+			expires := time.Unix(int64(exp), 0).Add(time.Duration(s.cfg().JWTLeeway))
+			s.oauth.revoke(fmt.Sprint(claims["jti"]), expires, s.now())
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleOAuthUserinfo returns the claims of the user an access token with
+// the openid scope was issued for
+func (s *Server) handleOAuthUserinfo() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="httpbin"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		claims, err := s.verifyOAuthAccessToken(r, token)
+		if err != nil {
+			description := strings.NewReplacer(`"`, "'", `\`, "/").Replace(err.Error())
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="httpbin", error="invalid_token", error_description="%s"`, description))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		scope, _ := claims["scope"].(string)
+		user := s.oauthUserByName(fmt.Sprint(claims["sub"]))
+		if user == nil || !contains(strings.Fields(scope), "openid") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="httpbin", error="insufficient_scope", scope="openid"`)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		resp := map[string]interface{}{}
+		for name, val := range user.Claims {
+			resp[name] = val
+		}
+		resp["sub"] = user.Username
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// verifyOAuthAccessToken validates an access token issued by the provider
+// and returns its claims
+func (s *Server) verifyOAuthAccessToken(r *http.Request, token string) (map[string]interface{}, error) {
+	_, claims, err := s.verifyJWT(token, jwtValidation{issuer: s.oauthIssuer(r), leeway: time.Duration(s.cfg().JWTLeeway)})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims["client_id"]; !ok {
+		return nil, fmt.Errorf("not an access token")
+	}
+	if s.oauth.isRevoked(fmt.Sprint(claims["jti"])) {
+		return nil, fmt.Errorf("token has been revoked")
+	}
+	return claims, nil
+}
+
+// authenticateOAuthClient identifies the client from Basic credentials or
+// the client_id and client_secret form fields. Public clients only need
+// their client_id.
+func (s *Server) authenticateOAuthClient(r *http.Request) (*OAuthClient, *oauthError) {
+	if err := r.ParseForm(); err != nil {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "unable to parse form: %v", err)
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	client := s.oauthClientByID(id)
+	if client == nil {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_client", "unknown client '%s'", id)
+	}
+	if client.Secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(client.Secret)) != 1 {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_client", "invalid client credentials")
+	}
+	return client, nil
+}
+
+// oauthUser returns the user of a request: the one whose Basic
+// credentials were sent, the one named by login_hint, or the first user
+func (s *Server) oauthUser(r *http.Request) (*OAuthUser, bool) {
+	if name, password, ok := r.BasicAuth(); ok {
+		user := s.oauthUserByName(name)
+		if user == nil || subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) != 1 {
+			return nil, false
+		}
+		return user, true
+	}
+	if hint := r.FormValue("login_hint"); hint != "" {
+		user := s.oauthUserByName(hint)
+		return user, user != nil
+	}
+	return &s.oauthUsers()[0], true
+}
+
+func (s *Server) oauthUsers() []OAuthUser {
+	if users := s.cfg().OAuthUsers; len(users) > 0 {
+		return users
+	}
+	return defaultOAuthUsers
+}
+
+func (s *Server) oauthUserByName(name string) *OAuthUser {
+	users := s.oauthUsers()
+	for i := range users {
+		if users[i].Username == name {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) oauthClientByID(id string) *OAuthClient {
+	clients := s.cfg().OAuthClients
+	if len(clients) == 0 {
+		clients = defaultOAuthClients
+	}
+	for i := range clients {
+		if clients[i].ID == id {
+			return &clients[i]
+		}
+	}
+	return nil
+}
+
+// oauthIssuer returns Config.JWTIssuer or else the server's base URL
+func (s *Server) oauthIssuer(r *http.Request) string {
+	if issuer := s.cfg().JWTIssuer; issuer != "" {
+		return issuer
+	}
+	return getBaseURL(r) + s.basePath()
+}
+
+// oauthSigningAlg picks the algorithm tokens are signed with, preferring
+// the asymmetric keys so clients can verify them with the JWKS
+func (s *Server) oauthSigningAlg() (string, error) {
+	for _, alg := range []string{"RS256", "ES256", "ES384", "ES512", "EdDSA"} {
+		if len(s.jwtKeys().find(alg, "", true)) > 0 {
+			return alg, nil
+		}
+	}
+	if s.cfg().JWTSecret != "" {
+		return "HS256", nil
+	}
+	return "", fmt.Errorf("no key to sign tokens with")
+}
+
+// checkOAuthScope returns the requested scope, or the client's scopes when
+// none was requested, failing when the client isn't allowed a scope
+func checkOAuthScope(client *OAuthClient, scope string) (string, *oauthError) {
+	if scope == "" {
+		return strings.Join(client.Scopes, " "), nil
+	}
+	if len(client.Scopes) > 0 {
+		for _, sc := range strings.Fields(scope) {
+			if !contains(client.Scopes, sc) {
+				return "", newOAuthError(http.StatusBadRequest, "invalid_scope", "scope '%s' is not allowed", sc)
+			}
+		}
+	}
+	return scope, nil
+}
+
+// checkPKCE reports whether the verifier matches the grant's code
+// challenge, if there was one
+func checkPKCE(g *oauthGrant, verifier string) bool {
+	if g.challenge == "" {
+		return true
+	}
+	if verifier == "" {
+		return false
+	}
+	expected := verifier
+	if g.challengeMethod == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(g.challenge)) == 1
+}
+
+// randomUserCode returns a user code such as WDJB-MJHT, using consonants
+// so it can't spell words or be misread
+func randomUserCode() string {
+	const alphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	b := make([]byte, 8)
+	rand.Read(b)
+	code := make([]byte, 0, 9)
+	for i, c := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, alphabet[int(c)%len(alphabet)])
+	}
+	return string(code)
+}
+
+func contains(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package httpbin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postForm(handler http.Handler, target string, form url.Values, user, password string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "http://test.com"+target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if user != "" {
+		r.SetBasicAuth(user, password)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func decodeOAuth(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("Failed to parse response. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+}
+
+// authorize follows /oauth/authorize and returns the redirect parameters
+func authorize(t *testing.T, handler http.Handler, query string) url.Values {
+	w := serve(handler, "GET", "/oauth/authorize?"+query, "")
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect from authorize. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Failed to parse redirect. Err: %v", err)
+	}
+	return location.Query()
+}
+
+func TestOAuthAuthorizationCode(t *testing.T) {
+	handler := newTestServer(t, jwtTestClock).Handler()
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	params := authorize(t, handler, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"httpbin-public"},
+		"redirect_uri":          {"http://app.test/callback"},
+		"scope":                 {"openid email"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}.Encode())
+	if params.Get("state") != "xyz" || params.Get("code") == "" {
+		t.Fatalf("Expected a code and the state, got %v", params)
+	}
+
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"httpbin-public"},
+		"code":          {params.Get("code")},
+		"redirect_uri":  {"http://app.test/callback"},
+		"code_verifier": {"wrong"},
+	}
+	if w := postForm(handler, "/oauth/token", exchange, "", ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid_grant") {
+		t.Errorf("Expected a wrong code_verifier to be rejected. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	// a failed exchange uses up the code
+	params = authorize(t, handler, "response_type=code&client_id=httpbin-public&redirect_uri=http://app.test/callback&scope=openid+email&nonce=n-0S6&code_challenge="+challenge+"&code_challenge_method=S256")
+	exchange.Set("code", params.Get("code"))
+	exchange.Set("code_verifier", verifier)
+	w := postForm(handler, "/oauth/token", exchange, "", "")
+	var tokens oauthTokenResponse
+	decodeOAuth(t, w, &tokens)
+	if w.Code != http.StatusOK || tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.IDToken == "" || tokens.ExpiresIn != 3600 {
+		t.Fatalf("Expected access, refresh and ID tokens. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected tokens not to be cached")
+	}
+	if w := postForm(handler, "/oauth/token", exchange, "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a code to only be usable once, got %d", w.Code)
+	}
+
+	var id jwtResponse
+	decodeOAuth(t, serveJWT(handler, "/jwt?iss=http://test.com&aud=httpbin-public", tokens.IDToken), &id)
+	if !id.Authenticated || id.Claims["sub"] != "user" || id.Claims["nonce"] != "n-0S6" || id.Claims["email"] != "user@example.com" {
+		t.Errorf("Unexpected ID token claims: %+v", id)
+	}
+
+	r := httptest.NewRequest("GET", "http://test.com/oauth/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	userinfo := httptest.NewRecorder()
+	handler.ServeHTTP(userinfo, r)
+	var claims map[string]interface{}
+	decodeOAuth(t, userinfo, &claims)
+	if claims["sub"] != "user" || claims["name"] != "Test User" {
+		t.Errorf("Unexpected userinfo: %v", claims)
+	}
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {"httpbin-public"}, "refresh_token": {tokens.RefreshToken}, "scope": {"openid"}}
+	w = postForm(handler, "/oauth/token", refresh, "", "")
+	var refreshed oauthTokenResponse
+	decodeOAuth(t, w, &refreshed)
+	if w.Code != http.StatusOK || refreshed.Scope != "openid" || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("Expected a rotated refresh token with a narrowed scope. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+	if w := postForm(handler, "/oauth/token", refresh, "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a rotated refresh token to be rejected, got %d", w.Code)
+	}
+}
+
+func TestOAuthGrantsOfOtherClients(t *testing.T) {
+	handler := newTestServer(t, jwtTestClock).Handler()
+
+	params := authorize(t, handler, "response_type=code&client_id=httpbin&redirect_uri=http://app.test/callback")
+	exchange := url.Values{"grant_type": {"authorization_code"}, "code": {params.Get("code")}, "redirect_uri": {"http://app.test/callback"}}
+	other := url.Values{"client_id": {"httpbin-public"}}
+	for key, val := range exchange {
+		other[key] = val
+	}
+	if w := postForm(handler, "/oauth/token", other, "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected another client's code to be rejected, got %d", w.Code)
+	}
+	w := postForm(handler, "/oauth/token", exchange, "httpbin", "secret")
+	var tokens oauthTokenResponse
+	decodeOAuth(t, w, &tokens)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the code to survive another client's attempt. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}}
+	other = url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}, "client_id": {"httpbin-public"}}
+	if w := postForm(handler, "/oauth/token", other, "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected another client's refresh token to be rejected, got %d", w.Code)
+	}
+	postForm(handler, "/oauth/revoke", url.Values{"token": {tokens.RefreshToken}, "client_id": {"httpbin-public"}}, "", "")
+	if w := postForm(handler, "/oauth/token", refresh, "httpbin", "secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected the refresh token to survive another client's attempts. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	var device oauthDeviceResponse
+	decodeOAuth(t, postForm(handler, "/oauth/device_authorization", url.Values{"client_id": {"httpbin-public"}}, "", ""), &device)
+	approve := httptest.NewRequest("GET", "http://test.com/oauth/device?user_code="+device.UserCode, nil)
+	approve.SetBasicAuth("user", "passwd")
+	handler.ServeHTTP(httptest.NewRecorder(), approve)
+	poll := url.Values{"grant_type": {deviceCodeGrantType}, "device_code": {device.DeviceCode}}
+	if w := postForm(handler, "/oauth/token", poll, "httpbin", "secret"); !strings.Contains(w.Body.String(), "invalid_grant") {
+		t.Errorf("Expected another client's device code to be rejected, got %s", w.Body.String())
+	}
+	poll.Set("client_id", "httpbin-public")
+	if w := postForm(handler, "/oauth/token", poll, "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected the device code to survive another client's poll. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestOAuthStoreEviction(t *testing.T) {
+	var store oauthStore
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store.revoke("expired", now.Add(-time.Minute), now)
+	store.revoke("first", now.Add(time.Minute), now)
+	for i := 2; i < maxOAuthGrants; i++ {
+		store.revoke(fmt.Sprint(i), now.Add(time.Hour), now)
+	}
+	store.revoke("new", now.Add(time.Hour), now)
+	if !store.isRevoked("first") || store.isRevoked("expired") || !store.isRevoked("new") {
+		t.Errorf("Expected only expired revocations to be pruned while there is room")
+	}
+
+	store.revoke("newer", now.Add(time.Hour), now)
+	if store.isRevoked("first") || !store.isRevoked("2") || !store.isRevoked("newer") {
+		t.Errorf("Expected the revocation expiring first to be evicted")
+	}
+	if len(store.revoked) != maxOAuthGrants {
+		t.Errorf("Expected %d revocations, got %d", maxOAuthGrants, len(store.revoked))
+	}
+}
+
+func TestOAuthAuthorizeErrors(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{
+		OAuthClients: []OAuthClient{{ID: "app", Secret: "s3cret", RedirectURIs: []string{"http://app.test/cb"}, Scopes: []string{"read"}}},
+	}), jwtTestClock).Handler()
+
+	if w := serve(handler, "GET", "/oauth/authorize?response_type=code&client_id=app&redirect_uri=http://evil.test/cb", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unregistered redirect_uri not to be redirected to, got %d", w.Code)
+	}
+	testCases := []struct {
+		query string
+		err   string
+	}{
+		{"response_type=token&client_id=app", "unsupported_response_type"},
+		{"response_type=code&client_id=app&scope=write", "invalid_scope"},
+		{"response_type=code&client_id=app&login_hint=nobody", "access_denied"},
+	}
+	for _, tc := range testCases {
+		params := authorize(t, handler, tc.query)
+		if params.Get("error") != tc.err {
+			t.Errorf("Expected %s to redirect with %s, got %v", tc.query, tc.err, params)
+		}
+	}
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	handler := newTestServer(t, jwtTestClock).Handler()
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {"read write"}}
+
+	if w := postForm(handler, "/oauth/token", form, "httpbin", "wrong"); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected a wrong secret to be rejected with a challenge. Status: %d", w.Code)
+	}
+
+	w := postForm(handler, "/oauth/token", form, "httpbin", "secret")
+	var tokens oauthTokenResponse
+	decodeOAuth(t, w, &tokens)
+	if w.Code != http.StatusOK || tokens.RefreshToken != "" || tokens.IDToken != "" || tokens.Scope != "read write" {
+		t.Fatalf("Expected only an access token. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	var introspection map[string]interface{}
+	decodeOAuth(t, postForm(handler, "/oauth/introspect", url.Values{"token": {tokens.AccessToken}}, "httpbin", "secret"), &introspection)
+	if introspection["active"] != true || introspection["client_id"] != "httpbin" || introspection["scope"] != "read write" {
+		t.Errorf("Unexpected introspection: %v", introspection)
+	}
+
+	if w := postForm(handler, "/oauth/revoke", url.Values{"token": {tokens.AccessToken}}, "httpbin", "secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected revocation to succeed, got %d", w.Code)
+	}
+	decodeOAuth(t, postForm(handler, "/oauth/introspect", url.Values{"token": {tokens.AccessToken}}, "httpbin", "secret"), &introspection)
+	if introspection["active"] != false {
+		t.Errorf("Expected a revoked token to be inactive: %v", introspection)
+	}
+}
+
+func TestOAuthDeviceCode(t *testing.T) {
+	now := jwtTestTime
+	handler := newTestServer(t, withTestClock(&now)).Handler()
+	w := postForm(handler, "/oauth/device_authorization", url.Values{"client_id": {"httpbin-public"}, "scope": {"openid"}}, "", "")
+	var device oauthDeviceResponse
+	decodeOAuth(t, w, &device)
+	if w.Code != http.StatusOK || device.UserCode == "" || device.VerificationURI != "http://test.com/oauth/device" {
+		t.Fatalf("Unexpected device authorization. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	poll := url.Values{"grant_type": {deviceCodeGrantType}, "client_id": {"httpbin-public"}, "device_code": {device.DeviceCode}}
+	if w := postForm(handler, "/oauth/token", poll, "", ""); !strings.Contains(w.Body.String(), "authorization_pending") {
+		t.Errorf("Expected authorization_pending before approval, got %s", w.Body.String())
+	}
+
+	approve := httptest.NewRequest("GET", "http://test.com/oauth/device?user_code="+device.UserCode, nil)
+	approve.SetBasicAuth("user", "passwd")
+	approved := httptest.NewRecorder()
+	handler.ServeHTTP(approved, approve)
+	if approved.Code != http.StatusOK {
+		t.Fatalf("Expected the user code to be approved. Status: %d, Body: %s", approved.Code, approved.Body.String())
+	}
+
+	w = postForm(handler, "/oauth/token", poll, "", "")
+	var tokens oauthTokenResponse
+	decodeOAuth(t, w, &tokens)
+	if w.Code != http.StatusOK || tokens.IDToken == "" {
+		t.Errorf("Expected tokens after approval. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	w = postForm(handler, "/oauth/device_authorization", url.Values{"client_id": {"httpbin-public"}}, "", "")
+	decodeOAuth(t, w, &device)
+	now = now.Add(oauthDeviceCodeTTL)
+	poll.Set("device_code", device.DeviceCode)
+	if w := postForm(handler, "/oauth/token", poll, "", ""); !strings.Contains(w.Body.String(), "expired_token") {
+		t.Errorf("Expected expired_token after the device code expired, got %s", w.Body.String())
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{BasePath: "/httpbin", JWTIssuer: "https://idp.test"}), jwtTestClock).Handler()
+	var discovery map[string]interface{}
+	decodeOAuth(t, serve(handler, "GET", "/httpbin/.well-known/openid-configuration", ""), &discovery)
+	if discovery["issuer"] != "https://idp.test" || discovery["token_endpoint"] != "http://test.com/httpbin/oauth/token" {
+		t.Errorf("Unexpected discovery document: %v", discovery)
+	}
+
+	var jwks jwkSet
+	decodeOAuth(t, serve(handler, "GET", "/httpbin/.well-known/jwks.json", ""), &jwks)
+	if len(jwks.Keys) == 0 {
+		t.Errorf("Expected the JWKS to publish keys")
+	}
+}
+
+func TestOAuthUserinfoScope(t *testing.T) {
+	handler := newTestServer(t, jwtTestClock).Handler()
+	w := postForm(handler, "/oauth/token", url.Values{"grant_type": {"client_credentials"}}, "httpbin", "secret")
+	var tokens oauthTokenResponse
+	decodeOAuth(t, w, &tokens)
+
+	r := httptest.NewRequest("GET", "http://test.com/oauth/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	userinfo := httptest.NewRecorder()
+	handler.ServeHTTP(userinfo, r)
+	if userinfo.Code != http.StatusForbidden || !strings.Contains(userinfo.Header().Get("WWW-Authenticate"), "insufficient_scope") {
+		t.Errorf("Expected a token without a user to be rejected. Status: %d", userinfo.Code)
+	}
+}
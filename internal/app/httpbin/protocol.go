@@ -0,0 +1,173 @@
+package httpbin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type connInfoKey struct{}
+type requestStatsKey struct{}
+
+// connInfo tracks the requests served on a single client connection
+type connInfo struct {
+	requests int64
+	active   int64
+}
+
+// requestStats is a snapshot of a connection's activity taken when a request
+// arrived on it
+type requestStats struct {
+	index  int64
+	active int64
+}
+
+type http2Info struct {
+	Transport          string `json:"transport"`
+	ConnectionRequests int64  `json:"connection_requests,omitempty"`
+	ConcurrentStreams  int64  `json:"concurrent_streams,omitempty"`
+}
+
+// withConnInfo is used as http.Server.ConnContext to attach per connection
+// request tracking
+func withConnInfo(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connInfoKey{}, &connInfo{})
+}
+
+// trackConnections records how many requests have been, and are being,
+// served on each connection so HTTP/2 multiplexing can be observed from the
+// echo payload, and which connections were hijacked so shutdown can wait
+// for them
+func (s *Server) trackConnections(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			hw := &hijackWriter{ResponseWriter: w, conns: &s.hijacked}
+			defer hw.release()
+			w = hw
+		}
+
+		ci, ok := r.Context().Value(connInfoKey{}).(*connInfo)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		stats := &requestStats{
+			index:  atomic.AddInt64(&ci.requests, 1),
+			active: atomic.AddInt64(&ci.active, 1),
+		}
+		defer atomic.AddInt64(&ci.active, -1)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestStatsKey{}, stats)))
+	})
+}
+
+// hijackedConns are the connections taken over by handlers, such as
+// WebSockets, which http.Server.Shutdown no longer tracks
+type hijackedConns struct {
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func (h *hijackedConns) add(conn net.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns == nil {
+		h.conns = make(map[net.Conn]struct{})
+	}
+	h.conns[conn] = struct{}{}
+}
+
+func (h *hijackedConns) remove(conn net.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, conn)
+}
+
+func (h *hijackedConns) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns)
+}
+
+// wait blocks until the handlers of all hijacked connections have
+// returned, closing the remaining connections once ctx is done
+func (h *hijackedConns) wait(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for h.count() > 0 {
+		select {
+		case <-ctx.Done():
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			for conn := range h.conns {
+				conn.Close()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// hijackWriter registers the connection when its handler hijacks it, until
+// the handler returns
+type hijackWriter struct {
+	http.ResponseWriter
+	conns *hijackedConns
+	conn  net.Conn
+}
+
+func (hw *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := hw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		hw.conn = conn
+		hw.conns.add(conn)
+	}
+	return conn, buf, err
+}
+
+func (hw *hijackWriter) Flush() {
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (hw *hijackWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+func (hw *hijackWriter) release() {
+	if hw.conn != nil {
+		hw.conns.remove(hw.conn)
+	}
+}
+
+func getProtocol(r *http.Request) string {
+	return r.Proto
+}
+
+func getHTTP2(r *http.Request) *http2Info {
+	if r.ProtoMajor != 2 {
+		return nil
+	}
+
+	info := &http2Info{Transport: "h2c"}
+	if r.TLS != nil {
+		info.Transport = "h2"
+	}
+	if stats, ok := r.Context().Value(requestStatsKey{}).(*requestStats); ok {
+		info.ConnectionRequests = stats.index
+		info.ConcurrentStreams = stats.active
+	}
+	return info
+}
@@ -0,0 +1,141 @@
+package httpbin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nathanows/httpbin-go/pkg/jsonparser"
+)
+
+func TestH2C_PriorKnowledge(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(&Config{HTTP2: true}))
+	defer ts.Close()
+
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: transport}
+
+	for i, expected := range []string{"1", "2"} {
+		resp, err := client.Get(ts.URL + "/get")
+		if err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		parsed, err := jsonparser.ParseJSON(body)
+		if err != nil {
+			t.Fatalf("Unable to parse returned JSON. Err: %v", err)
+		}
+
+		if val := parsed.Path("protocol").String(); val != "HTTP/2.0" {
+			t.Errorf("Expected protocol to be HTTP/2.0, got: %s", val)
+		}
+		if val := parsed.Path("http2.transport").String(); val != "h2c" {
+			t.Errorf("Expected transport to be h2c, got: %s", val)
+		}
+		if val := parsed.Path("http2.connection_requests").Data(); val != float64(i+1) {
+			t.Errorf("Expected connection_requests to be %s, got: %v", expected, val)
+		}
+	}
+}
+
+func TestH2C_Upgrade(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(&Config{HTTP2: true}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect. Err: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte("GET /get?animal=dog HTTP/1.1\r\n" +
+		"Host: " + ts.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\n" +
+		"Upgrade: h2c\r\n" +
+		"HTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n"))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Failed to read response. Err: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "h2c" {
+		t.Fatalf("Expected 101 Switching Protocols to h2c, got: %s %v", resp.Status, resp.Header)
+	}
+
+	// the response to the upgraded request arrives on stream 1
+	conn.Write([]byte(h2cPreface))
+	conn.Write(http2Frame(http2FrameSettings, 0, 0, nil))
+	var body []byte
+	for {
+		header := make([]byte, 9)
+		if _, err := io.ReadFull(br, header); err != nil {
+			t.Fatalf("Failed to read frame. Err: %v", err)
+		}
+		payload := make([]byte, int(header[0])<<16|int(header[1])<<8|int(header[2]))
+		if _, err := io.ReadFull(br, payload); err != nil {
+			t.Fatalf("Failed to read frame. Err: %v", err)
+		}
+		const http2FrameData = 0x0
+		if header[3] == http2FrameData && binary.BigEndian.Uint32(header[5:]) == 1 {
+			body = append(body, payload...)
+			if header[4]&http2FlagEndStream != 0 {
+				break
+			}
+		}
+	}
+
+	parsed, err := jsonparser.ParseJSON(body)
+	if err != nil {
+		t.Fatalf("Unable to parse returned JSON. Err: %v", err)
+	}
+	if val := parsed.Path("protocol").String(); val != "HTTP/2.0" {
+		t.Errorf("Expected protocol to be HTTP/2.0, got: %s", val)
+	}
+	if val := parsed.Path("args.animal").String(); val != "dog" {
+		t.Errorf("Expected args.animal to be dog, got: %s", val)
+	}
+}
+
+func TestH2C_UpgradeWithBody(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(&Config{HTTP2: true}))
+	defer ts.Close()
+
+	req, _ := http.NewRequest("POST", ts.URL+"/post", strings.NewReader("a=1"))
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", "")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Errorf("Expected requests with a body to stay on HTTP/1.1, got: %s %s", resp.Proto, resp.Status)
+	}
+}
+
+func TestHandleGet_Protocol(t *testing.T) {
+	target := "http://test.com/get"
+	req := newTestRequest(httpServer.handleGet(), target, "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	if val := req.parsedJSON.Path("protocol").String(); val != "HTTP/1.1" {
+		t.Errorf("Expected protocol to be HTTP/1.1, got: %s", val)
+	}
+	if val := req.parsedJSON.Path("http2"); val != nil {
+		t.Errorf("http2 should not be included for HTTP/1.1 requests")
+	}
+}
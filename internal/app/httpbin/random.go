@@ -0,0 +1,46 @@
+package httpbin
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedRand is a math/rand source that is safe for concurrent use
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// defaultRand is used by servers that weren't given a seed
+var defaultRand = newLockedRand(time.Now().UnixNano())
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
+
+// random returns the server's random source
+func (s *Server) random() *lockedRand {
+	if s.rand == nil {
+		return defaultRand
+	}
+	return s.rand
+}
@@ -1,8 +1,13 @@
 package httpbin
 
 import (
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
 )
 
 func (s *Server) handleRedirectTo() http.HandlerFunc {
@@ -19,3 +24,134 @@ func (s *Server) handleRedirectTo() http.HandlerFunc {
 		http.Redirect(w, r, url, statusCode)
 	}
 }
+
+// handleRedirect redirects n times before ending at /get, using relative
+// redirects unless ?absolute=true
+func (s *Server) handleRedirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("absolute") == "true" {
+			s.redirectN(w, r, "/absolute-redirect/%d", true)
+			return
+		}
+		s.redirectN(w, r, "/relative-redirect/%d", false)
+	}
+}
+
+// handleRelativeRedirect redirects n times with a relative Location
+func (s *Server) handleRelativeRedirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.redirectN(w, r, "/relative-redirect/%d", false)
+	}
+}
+
+// handleAbsoluteRedirect redirects n times with an absolute Location
+func (s *Server) handleAbsoluteRedirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.redirectN(w, r, "/absolute-redirect/%d", true)
+	}
+}
+
+// redirectN redirects to the next hop of the chain formatted by next, or to
+// /get on the last hop
+func (s *Server) redirectN(w http.ResponseWriter, r *http.Request, next string, absolute bool) {
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || n < 1 {
+		http.Error(w, "Invalid number of redirects", http.StatusBadRequest)
+		return
+	}
+
+	location := s.path("/get")
+	if n > 1 {
+		location = s.path(fmt.Sprintf(next, n-1))
+	}
+	if absolute {
+		location = getBaseURL(r) + location
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(redirectStatus(r, http.StatusFound))
+}
+
+// handleRedirectLoop redirects to itself forever, for testing a client's
+// limit on the number of hops
+func (s *Server) handleRedirectLoop() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", s.path("/redirect-loop"))
+		w.WriteHeader(redirectStatus(r, http.StatusFound))
+	}
+}
+
+// handleCrossHostRedirect redirects to /get on the host given by ?host=,
+// keeping the scheme, for testing that credentials are dropped when a
+// redirect leaves the original host
+func (s *Server) handleCrossHostRedirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			http.Error(w, "Missing host", http.StatusBadRequest)
+			return
+		}
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		w.Header().Set("Location", fmt.Sprintf("%s://%s%s", scheme, host, s.path("/get")))
+		w.WriteHeader(redirectStatus(r, http.StatusFound))
+	}
+}
+
+// handleCrossSchemeRedirect redirects to /get switching between http and
+// https, on the host given by ?host= or the same host. The port is taken
+// from ?port=, otherwise it is dropped so the client uses the default port
+// of the new scheme: the port the request came in on speaks the other one.
+func (s *Server) handleCrossSchemeRedirect() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		host := query.Get("host")
+		if host == "" {
+			host = r.Host
+		}
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port := query.Get("port"); port != "" {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				http.Error(w, "Invalid port", http.StatusBadRequest)
+				return
+			}
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		scheme := "https"
+		if r.TLS != nil {
+			scheme = "http"
+		}
+		w.Header().Set("Location", fmt.Sprintf("%s://%s%s", scheme, host, s.path("/get")))
+		w.WriteHeader(redirectStatus(r, http.StatusFound))
+	}
+}
+
+// handleRedirectMethod redirects to /anything with the given redirect
+// code, so the method and body the client follows up with are echoed:
+// after a POST, 301, 302 and 303 are usually followed with a GET while 307
+// and 308 replay the POST
+func (s *Server) handleRedirectMethod() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code, err := strconv.Atoi(mux.Vars(r)["code"])
+		if err != nil || code < 300 || code > 399 {
+			http.Error(w, "Invalid redirect status code", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Location", s.path("/anything"))
+		w.WriteHeader(code)
+	}
+}
+
+// redirectStatus returns the redirect code given by ?status_code=, or def
+func redirectStatus(r *http.Request, def int) int {
+	if code, err := strconv.Atoi(r.URL.Query().Get("status_code")); err == nil && code >= 300 && code < 400 {
+		return code
+	}
+	return def
+}
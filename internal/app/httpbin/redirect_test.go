@@ -1,10 +1,15 @@
 package httpbin
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/nathanows/httpbin-go/pkg/jsonparser"
 )
 
 func (s *Server) TestHandleRedirectTo(t *testing.T) {
@@ -24,3 +29,134 @@ func (s *Server) TestHandleRedirectTo(t *testing.T) {
 		t.Errorf("Expected Location to be %s, got: %s", url, val)
 	}
 }
+
+func TestHandleRedirectN(t *testing.T) {
+	testCases := []struct {
+		handler  http.HandlerFunc
+		target   string
+		n        string
+		location string
+	}{
+		{reqInspectServer.handleRedirect(), "http://test.com/redirect/3", "3", "/relative-redirect/2"},
+		{reqInspectServer.handleRedirect(), "http://test.com/redirect/3?absolute=true", "3", "http://test.com/absolute-redirect/2"},
+		{reqInspectServer.handleRelativeRedirect(), "http://test.com/relative-redirect/1", "1", "/get"},
+		{reqInspectServer.handleAbsoluteRedirect(), "http://test.com/absolute-redirect/2", "2", "http://test.com/absolute-redirect/1"},
+		{reqInspectServer.handleAbsoluteRedirect(), "http://test.com/absolute-redirect/1", "1", "http://test.com/get"},
+	}
+	for _, tc := range testCases {
+		req := newTestRequest(tc.handler, tc.target, "GET", testReqStatus([]int{302}))
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"n": tc.n})
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		if err := req.validateStatusCode(); err != nil {
+			t.Errorf("Failed request base validations. Failure: %v", err)
+		}
+		if val := req.response.Header().Get("Location"); val != tc.location {
+			t.Errorf("Expected Location for %s to be %s, got: %s", tc.target, tc.location, val)
+		}
+	}
+
+	req := newTestRequest(reqInspectServer.handleRedirect(), "http://test.com/redirect/0", "GET", testReqStatus([]int{400}))
+	req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"n": "0"})
+	if err := req.make(); err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+}
+
+func TestRedirects_Follow(t *testing.T) {
+	ts := newHTTPTestServer(t)
+	defer ts.Close()
+
+	var hops int
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		hops = len(via)
+		if len(via) >= 10 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	}}
+
+	resp, err := client.Get(ts.URL + "/redirect/4?absolute=true")
+	if err != nil {
+		t.Fatalf("Failed to follow redirects. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.Path != "/get" || hops != 4 {
+		t.Errorf("Expected to end at /get after 4 hops, got: %s after %d", resp.Request.URL.Path, hops)
+	}
+
+	if _, err := client.Get(ts.URL + "/redirect-loop"); err == nil || hops != 10 {
+		t.Errorf("Expected the redirect loop to hit the hop limit, got: %v after %d", err, hops)
+	}
+
+	for code, method := range map[string]string{"303": "GET", "307": "POST", "308": "POST"} {
+		resp, err := client.Post(ts.URL+"/redirect-method/"+code, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("Failed to follow redirect. Err: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		parsed, err := jsonparser.ParseJSON(body)
+		if err != nil {
+			t.Fatalf("Unable to parse returned JSON. Err: %v", err)
+		}
+		if val := parsed.Path("method").String(); val != method {
+			t.Errorf("Expected %s to be followed with %s, got: %s", code, method, val)
+		}
+		if data := parsed.Path("data").String(); (method == "POST") != (data == "payload") {
+			t.Errorf("Expected body to be replayed only for %s, got: %q", method, data)
+		}
+	}
+}
+
+func TestHandleCrossRedirects(t *testing.T) {
+	req := newTestRequest(reqInspectServer.handleCrossHostRedirect(), "http://test.com/cross-host-redirect?host=other.com:8080", "GET", testReqStatus([]int{302}))
+	if err := req.make(); err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if val := req.response.Header().Get("Location"); val != "http://other.com:8080/get" {
+		t.Errorf("Expected Location to be on other.com, got: %s", val)
+	}
+
+	req = newTestRequest(reqInspectServer.handleCrossSchemeRedirect(), "http://test.com/cross-scheme-redirect?status_code=307", "GET", testReqStatus([]int{307}))
+	if err := req.make(); err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+	if val := req.response.Header().Get("Location"); val != "https://test.com/get" {
+		t.Errorf("Expected Location to switch to https, got: %s", val)
+	}
+
+	testCases := map[string]string{
+		"http://test.com:8080/cross-scheme-redirect":                   "https://test.com/get",
+		"http://test.com:8080/cross-scheme-redirect?port=8443":         "https://test.com:8443/get",
+		"http://test.com:8080/cross-scheme-redirect?host=[::1]:8080":   "https://[::1]/get",
+		"http://test.com:8080/cross-scheme-redirect?host=::1&port=443": "https://[::1]:443/get",
+		"http://[::1]/cross-scheme-redirect":                           "https://[::1]/get",
+		"http://test.com/cross-scheme-redirect?host=[::1]":             "https://[::1]/get",
+		"http://test.com/cross-scheme-redirect?host=[::1]&port=8443":   "https://[::1]:8443/get",
+	}
+	for target, expected := range testCases {
+		req = newTestRequest(reqInspectServer.handleCrossSchemeRedirect(), target, "GET", testReqStatus([]int{302}))
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		if val := req.response.Header().Get("Location"); val != expected {
+			t.Errorf("Expected Location of %s to be %s, got: %s", target, expected, val)
+		}
+	}
+
+	req = newTestRequest(reqInspectServer.handleCrossSchemeRedirect(), "http://test.com/cross-scheme-redirect?port=http", "GET", testReqStatus([]int{400}))
+	if err := req.make(); err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Expected an invalid port to be rejected. Failure: %v", err)
+	}
+}
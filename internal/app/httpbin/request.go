@@ -1,14 +1,26 @@
 package httpbin
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
+	"unicode/utf8"
 )
 
+// maxMultipartMemory is the amount of a multipart body held in memory
+// before the remaining file parts are spooled to disk.
+const maxMultipartMemory = 32 << 20
+
 // Request represents http request metadata
 type Request struct {
 	Args      map[string]string `json:"args"`
@@ -16,20 +28,27 @@ type Request struct {
 	Files     map[string]string `json:"files"`
 	Form      map[string]string `json:"form"`
 	Headers   map[string]string `json:"headers"`
-	JSON      string            `json:"json"`
+	JSON      interface{}       `json:"json"`
 	Origin    string            `json:"origin"`
 	URL       string            `json:"url"`
 	Method    string            `json:"method"`
 	UserAgent string            `json:"user-agent"`
 	Gzipped   bool              `json:"gzipped"`
+	Protocol  string            `json:"protocol"`
+	HTTP2     *http2Info        `json:"http2,omitempty"`
+
+	argValues    url.Values
+	formValues   url.Values
+	headerValues http.Header
 }
 
 type requestKeys []string
 
-func returnRequestAsJSON(w http.ResponseWriter, r *http.Request, keys requestKeys) {
-	json, err := RequestToJSON(r, keys)
+func (s *Server) returnRequestAsJSON(w http.ResponseWriter, r *http.Request, keys requestKeys) {
+	json, err := s.requestToJSON(r, keys)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeRequestError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -37,10 +56,11 @@ func returnRequestAsJSON(w http.ResponseWriter, r *http.Request, keys requestKey
 	w.Write(json)
 }
 
-func returnRequestGzipped(w http.ResponseWriter, r *http.Request, keys requestKeys) {
-	resp, err := RequestToJSON(r, keys)
+func (s *Server) returnRequestGzipped(w http.ResponseWriter, r *http.Request, keys requestKeys) {
+	resp, err := s.requestToJSON(r, keys)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeRequestError(w, err)
+		return
 	}
 
 	w.Header().Add("Content-Type", "application/json")
@@ -69,19 +89,166 @@ func RequestToJSON(r *http.Request, keys requestKeys) ([]byte, error) {
 	return json, nil
 }
 
+// requestToJSON behaves like RequestToJSON but honours the server's output
+// options.
+func (s *Server) requestToJSON(r *http.Request, keys requestKeys) ([]byte, error) {
+	req, err := parseRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	json, err := toJSON(s.selectRequestKeys(req, keys))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal to json: %v", err)
+	}
+
+	return json, nil
+}
+
+// selectRequestKeys selects the requested keys from req, expanding repeated
+// args, form fields and headers into arrays when multi-value output is
+// enabled.
+func (s *Server) selectRequestKeys(req *Request, keys requestKeys) map[string]interface{} {
+	selected := req.selectKeys(keys)
+	if s.cfg().MultiValue {
+		req.expandMultiValues(selected)
+	}
+	return selected
+}
+
 func parseRequest(r *http.Request) (*Request, error) {
-	return &Request{
+	req := &Request{
 		Args:      getArgs(r),
-		Data:      "",
 		Files:     make(map[string]string),
 		Form:      make(map[string]string),
 		Headers:   getHeaders(r),
-		JSON:      "",
 		Method:    getMethod(r),
 		Origin:    getOrigin(r),
 		URL:       getURL(r),
 		UserAgent: getUserAgent(r),
-	}, nil
+		Protocol:  getProtocol(r),
+		HTTP2:     getHTTP2(r),
+
+		argValues:    r.URL.Query(),
+		headerValues: r.Header,
+	}
+
+	if err := req.parseBody(r); err != nil {
+		return nil, &bodyError{err}
+	}
+
+	return req, nil
+}
+
+// bodyError reports a request body that could not be read or parsed
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string { return e.err.Error() }
+
+func (e *bodyError) Unwrap() error { return e.err }
+
+// writeRequestError answers a request that could not be parsed: 413 when
+// its body is larger than the configured maximum, 400 when the body is
+// malformed and 500 otherwise
+func writeRequestError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var bodyErr *bodyError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &bodyErr):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseBody reads the request body and populates data, form, files and json
+// the same way the original httpbin does. The body is replaced with an
+// in-memory copy so it can still be read by the caller.
+func (req *Request) parseBody(r *http.Request) error {
+	if r.Body == nil {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Errorf("failed to parse form body: %v", err)
+		}
+		for key, vals := range values {
+			req.Form[key] = strings.Join(vals, ",")
+		}
+		req.formValues = values
+	case "multipart/form-data":
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		form, err := mr.ReadForm(maxMultipartMemory)
+		if err != nil {
+			return fmt.Errorf("failed to parse multipart body: %v", err)
+		}
+		defer form.RemoveAll()
+
+		for key, vals := range form.Value {
+			req.Form[key] = strings.Join(vals, ",")
+		}
+		req.formValues = form.Value
+		for key, headers := range form.File {
+			var contents []string
+			for _, fh := range headers {
+				content, err := readFormFile(fh)
+				if err != nil {
+					return fmt.Errorf("failed to read file %s: %v", fh.Filename, err)
+				}
+				contents = append(contents, jsonSafe(content, fh.Header.Get("Content-Type")))
+			}
+			req.Files[key] = strings.Join(contents, ",")
+		}
+	default:
+		req.Data = jsonSafe(body, contentType)
+	}
+
+	if len(body) > 0 {
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err == nil {
+			req.JSON = decoded
+		}
+	}
+
+	return nil
+}
+
+func readFormFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// jsonSafe returns content as a string when it is valid UTF-8, otherwise as a
+// base64 encoded data URL so binary payloads survive JSON encoding.
+func jsonSafe(content []byte, contentType string) string {
+	if utf8.Valid(content) {
+		return string(content)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(content))
 }
 
 func toJSON(in map[string]interface{}) ([]byte, error) {
@@ -99,17 +266,21 @@ func getURL(r *http.Request) string {
 		return r.URL.String()
 	}
 
-	scheme := r.URL.Scheme
-	if scheme == "" {
-		if r.TLS == nil {
-			scheme = "http"
-		} else {
-			scheme = "https"
-		}
+	url := fmt.Sprintf("%s%s", getBaseURL(r), r.URL)
+	return url
+}
+
+// getBaseURL returns the scheme and host the request was sent to
+func getBaseURL(r *http.Request) string {
+	if r.URL.IsAbs() {
+		return fmt.Sprintf("%s://%s", r.URL.Scheme, r.URL.Host)
 	}
 
-	url := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL)
-	return url
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
 }
 
 func getHeaders(r *http.Request) map[string]string {
@@ -157,10 +328,53 @@ func (req *Request) selectKeys(keys requestKeys) map[string]interface{} {
 	out := make(map[string]interface{}, rt.NumField())
 	for i := 0; i < rt.NumField(); i++ {
 		field := rt.Field(i)
-		jsonKey := field.Tag.Get("json")
-		if ks[jsonKey] {
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		jsonKey, omitEmpty := tag[0], len(tag) > 1 && tag[1] == "omitempty"
+		if ks[jsonKey] && !(omitEmpty && rv.Field(i).IsZero()) {
 			out[jsonKey] = rv.Field(i).Interface()
 		}
 	}
 	return out
 }
+
+// expandMultiValues replaces the comma joined args, form and headers in
+// selected with values that are a string when single-valued and an array
+// when the key was repeated, matching the original httpbin.
+func (req *Request) expandMultiValues(selected map[string]interface{}) {
+	values := map[string]map[string][]string{
+		"args":    req.argValues,
+		"form":    req.formValues,
+		"headers": req.headerValues,
+	}
+	for key, vals := range values {
+		if _, ok := selected[key]; ok {
+			selected[key] = multiValued(vals)
+		}
+	}
+}
+
+func multiValued(values map[string][]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for key, vals := range values {
+		if len(vals) == 1 {
+			out[key] = vals[0]
+		} else {
+			out[key] = vals
+		}
+	}
+	return out
+}
+
+// writeJSON writes resp as indented JSON with the given status code
+func writeJSON(w http.ResponseWriter, code int, resp interface{}) {
+	jsonResp, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonResp = append(jsonResp, "\n"...)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(jsonResp)
+}
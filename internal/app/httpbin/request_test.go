@@ -1,9 +1,12 @@
 package httpbin
 
 import (
+	"bytes"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/nathanows/httpbin-go/pkg/jsonparser"
@@ -112,6 +115,111 @@ func TestParseRequest_Args(t *testing.T) {
 	}
 }
 
+func TestParseRequest_Data(t *testing.T) {
+	target := "http://hbg.com/post"
+	r := httptest.NewRequest("POST", target, strings.NewReader("some raw body"))
+	r.Header.Set("Content-Type", "text/plain")
+
+	hbr, err := parseRequest(r)
+	if err != nil {
+		t.Errorf("Failed to ParseRequest. Err: %v", err)
+	}
+
+	if hbr.Data != "some raw body" {
+		t.Errorf("got %s, want %s", hbr.Data, "some raw body")
+	}
+	if hbr.JSON != nil {
+		t.Errorf("got %v, want nil json", hbr.JSON)
+	}
+}
+
+func TestParseRequest_Data_Binary(t *testing.T) {
+	target := "http://hbg.com/post"
+	r := httptest.NewRequest("POST", target, bytes.NewReader([]byte{0xff, 0xfe, 0x00}))
+	r.Header.Set("Content-Type", "application/octet-stream")
+
+	hbr, err := parseRequest(r)
+	if err != nil {
+		t.Errorf("Failed to ParseRequest. Err: %v", err)
+	}
+
+	expected := "data:application/octet-stream;base64,//4A"
+	if hbr.Data != expected {
+		t.Errorf("got %s, want %s", hbr.Data, expected)
+	}
+}
+
+func TestParseRequest_Form(t *testing.T) {
+	target := "http://hbg.com/post"
+	r := httptest.NewRequest("POST", target, strings.NewReader("name=steve&color=red&color=blue"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	hbr, err := parseRequest(r)
+	if err != nil {
+		t.Errorf("Failed to ParseRequest. Err: %v", err)
+	}
+
+	if hbr.Form["name"] != "steve" {
+		t.Errorf("got %s, want %s", hbr.Form["name"], "steve")
+	}
+	if hbr.Form["color"] != "red,blue" {
+		t.Errorf("got %s, want %s", hbr.Form["color"], "red,blue")
+	}
+	if hbr.Data != "" {
+		t.Errorf("got %s, want empty data", hbr.Data)
+	}
+}
+
+func TestParseRequest_Multipart(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "steve")
+	fw, _ := mw.CreateFormFile("upload", "hello.txt")
+	fw.Write([]byte("hello world"))
+	mw.Close()
+
+	target := "http://hbg.com/post"
+	r := httptest.NewRequest("POST", target, body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	hbr, err := parseRequest(r)
+	if err != nil {
+		t.Errorf("Failed to ParseRequest. Err: %v", err)
+	}
+
+	if hbr.Form["name"] != "steve" {
+		t.Errorf("got %s, want %s", hbr.Form["name"], "steve")
+	}
+	if hbr.Files["upload"] != "hello world" {
+		t.Errorf("got %s, want %s", hbr.Files["upload"], "hello world")
+	}
+}
+
+func TestParseRequest_JSON(t *testing.T) {
+	target := "http://hbg.com/post"
+	r := httptest.NewRequest("POST", target, strings.NewReader(`{"animal": "dog", "legs": 4}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	hbr, err := parseRequest(r)
+	if err != nil {
+		t.Errorf("Failed to ParseRequest. Err: %v", err)
+	}
+
+	decoded, ok := hbr.JSON.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected json to be decoded into an object, got: %T", hbr.JSON)
+	}
+	if decoded["animal"] != "dog" {
+		t.Errorf("got %v, want %s", decoded["animal"], "dog")
+	}
+	if decoded["legs"] != float64(4) {
+		t.Errorf("got %v, want %d", decoded["legs"], 4)
+	}
+	if hbr.Data != `{"animal": "dog", "legs": 4}` {
+		t.Errorf("got %s, want raw body in data", hbr.Data)
+	}
+}
+
 func TestToJSON(t *testing.T) {
 	req := &Request{
 		Args:    map[string]string{"test": "test,again"},
@@ -142,7 +250,7 @@ func TestToJSON(t *testing.T) {
 		{"headers.Accept", req.Headers["Accept"]},
 		{"headers.Something", req.Headers["Something"]},
 		{"url", req.URL},
-		{"json", req.JSON},
+		{"json", req.JSON.(string)},
 	}
 
 	for _, tc := range testCases {
@@ -218,3 +326,38 @@ func TestSelectKeys(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectRequestKeys_MultiValue(t *testing.T) {
+	target := "http://hbg.com/get?test=test1&test=test2&Something=1,2"
+	r := httptest.NewRequest("GET", target, nil)
+	r.Header["Accept"] = []string{"text/html, */*"}
+	r.Header["X-Repeated"] = []string{"one", "two"}
+
+	hbr, err := parseRequest(r)
+	if err != nil {
+		t.Errorf("Failed to ParseRequest. Err: %v", err)
+	}
+
+	server := &Server{config: &Config{MultiValue: true}}
+	selected := server.selectRequestKeys(hbr, requestKeys{"args", "headers", "form"})
+
+	args := selected["args"].(map[string]interface{})
+	if vals, ok := args["test"].([]string); !ok || len(vals) != 2 || vals[0] != "test1" || vals[1] != "test2" {
+		t.Errorf("got %v, want [test1 test2]", args["test"])
+	}
+	if args["Something"] != "1,2" {
+		t.Errorf("got %v, want %s", args["Something"], "1,2")
+	}
+
+	headers := selected["headers"].(map[string]interface{})
+	if headers["Accept"] != "text/html, */*" {
+		t.Errorf("got %v, want %s", headers["Accept"], "text/html, */*")
+	}
+	if vals, ok := headers["X-Repeated"].([]string); !ok || len(vals) != 2 {
+		t.Errorf("got %v, want [one two]", headers["X-Repeated"])
+	}
+
+	if form := selected["form"].(map[string]interface{}); len(form) != 0 {
+		t.Errorf("got %v, want empty form", form)
+	}
+}
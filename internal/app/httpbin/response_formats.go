@@ -1,8 +1,8 @@
 package httpbin
 
 import (
+	"fmt"
 	"net/http"
-	"text/template"
 )
 
 const angryASCII = `
@@ -19,7 +19,7 @@ const angryASCII = `
 `
 
 const robotTxt = `User-agent: *
-Disallow: /deny
+Disallow: %s
 `
 
 func (s *Server) handleDeny() http.HandlerFunc {
@@ -32,16 +32,14 @@ func (s *Server) handleDeny() http.HandlerFunc {
 func (s *Server) handleEncodingUTF8() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/html; charset=utf-8")
-		tmpl := template.Must(template.ParseFiles("templates/UTF-8-demo.txt"))
-		tmpl.Execute(w, "")
+		s.assets().templates.ExecuteTemplate(w, "UTF-8-demo.txt", "")
 	}
 }
 
 func (s *Server) handleHTML() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/html; charset=utf-8")
-		tmpl := template.Must(template.ParseFiles("templates/moby.html"))
-		tmpl.Execute(w, "")
+		s.assets().templates.ExecuteTemplate(w, "moby.html", "")
 	}
 }
 
@@ -73,21 +71,20 @@ func (s *Server) handleJSON() http.HandlerFunc {
 func (s *Server) handleRobotsTxt() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "text/plain")
-		w.Write([]byte(robotTxt))
+		fmt.Fprintf(w, robotTxt, s.path("/deny"))
 	}
 }
 
 func (s *Server) handleXML() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/xml")
-		tmpl := template.Must(template.ParseFiles("templates/sample.xml"))
-		tmpl.Execute(w, "")
+		s.assets().templates.ExecuteTemplate(w, "sample.xml", "")
 	}
 }
 
 func (s *Server) handleGzip() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		keys := requestKeys{"headers", "method", "origin"}
-		returnRequestGzipped(w, r, keys)
+		s.returnRequestGzipped(w, r, keys)
 	}
 }
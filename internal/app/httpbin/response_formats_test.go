@@ -1,7 +1,6 @@
 package httpbin
 
 import (
-	"os"
 	"strings"
 	"testing"
 )
@@ -27,13 +26,11 @@ func TestHandleDeny(t *testing.T) {
 }
 
 func TestHandleEncodingUTF8(t *testing.T) {
-	os.Chdir("../../..")
 	target := "http://test.com/encoding/utf8"
 	req := newTestRequest(reqInspectServer.handleEncodingUTF8(), target, "GET")
 	if err := req.make(); err != nil {
 		t.Errorf("Failed to make request. Err: %v", err)
 	}
-	os.Chdir("internal/app/httpbin")
 
 	if err := req.validateStatusCode(); err != nil {
 		t.Errorf("Failed request base validations. Failure: %v", err)
@@ -49,13 +46,11 @@ func TestHandleEncodingUTF8(t *testing.T) {
 }
 
 func TestHandleHTML(t *testing.T) {
-	os.Chdir("../../..")
 	target := "http://test.com/html"
 	req := newTestRequest(reqInspectServer.handleHTML(), target, "GET")
 	if err := req.make(); err != nil {
 		t.Errorf("Failed to make request. Err: %v", err)
 	}
-	os.Chdir("internal/app/httpbin")
 
 	if err := req.validateStatusCode(); err != nil {
 		t.Errorf("Failed request base validations. Failure: %v", err)
@@ -112,13 +107,11 @@ func TestHandleRobotsTxt(t *testing.T) {
 }
 
 func TestHandleXML(t *testing.T) {
-	os.Chdir("../../..")
 	target := "http://test.com/encoding/xml"
 	req := newTestRequest(reqInspectServer.handleXML(), target, "GET")
 	if err := req.make(); err != nil {
 		t.Errorf("Failed to make request. Err: %v", err)
 	}
-	os.Chdir("internal/app/httpbin")
 
 	if err := req.validateStatusCode(); err != nil {
 		t.Errorf("Failed request base validations. Failure: %v", err)
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/satori/go.uuid"
@@ -18,14 +17,14 @@ func (s *Server) handleCache() http.HandlerFunc {
 			return
 		}
 
-		lastMod := time.Now().Format("Mon, 04 Oct 2018 23:08:16 GMT")
+		lastMod := s.now().Format("Mon, 04 Oct 2018 23:08:16 GMT")
 		w.Header().Set("Last-Modified", lastMod)
 
 		uuid := uuid.NewV4()
 		w.Header().Set("ETag", uuid.String())
 
 		keys := requestKeys{"args", "headers", "origin", "url"}
-		returnRequestAsJSON(w, r, keys)
+		s.returnRequestAsJSON(w, r, keys)
 	}
 }
 
@@ -38,7 +37,7 @@ func (s *Server) handleCacheControl() http.HandlerFunc {
 		w.Header().Set("Cache-Control", cacheControlVal)
 
 		keys := requestKeys{"args", "headers", "origin", "url"}
-		returnRequestAsJSON(w, r, keys)
+		s.returnRequestAsJSON(w, r, keys)
 	}
 }
 
@@ -69,7 +68,7 @@ func (s *Server) handleETag() http.HandlerFunc {
 
 		w.Header().Set("ETag", etag)
 		keys := requestKeys{"args", "headers", "origin", "url"}
-		returnRequestAsJSON(w, r, keys)
+		s.returnRequestAsJSON(w, r, keys)
 	}
 }
 
@@ -0,0 +1,388 @@
+package httpbin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxTemplateOutput caps the size of a rendered response template
+	maxTemplateOutput = 1 << 20
+
+	// maxTemplateDuration caps how long a response template may run
+	maxTemplateDuration = time.Second
+
+	// maxTemplateRange caps the count of a range over a constant integer
+	maxTemplateRange = 10000
+
+	// templateDeadlineFunc is the helper injected into every range loop to
+	// stop templates that run past their deadline
+	templateDeadlineFunc = "_checkDeadline"
+)
+
+var (
+	errTemplateOutputTooLarge = errors.New("rendered template is too large")
+	errTemplateTimeout        = errors.New("template took too long to render")
+)
+
+// templateData is what response templates are executed with
+type templateData struct {
+	*Request
+
+	// Vars holds the path variables of the matched route
+	Vars map[string]string
+	// Path is the request path without the query string
+	Path string
+	// Query and RawHeaders hold every value of repeated query parameters
+	// and headers
+	Query      url.Values
+	RawHeaders http.Header
+}
+
+// Header returns the first value of the named request header
+func (d *templateData) Header(name string) string {
+	return d.RawHeaders.Get(name)
+}
+
+// Arg returns the first value of the named query parameter
+func (d *templateData) Arg(name string) string {
+	return d.Query.Get(name)
+}
+
+func newTemplateData(r *http.Request, vars map[string]string) (*templateData, error) {
+	req, err := parseRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return &templateData{
+		Request:    req,
+		Vars:       vars,
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		RawHeaders: r.Header,
+	}, nil
+}
+
+// templateFuncs are the helpers available to response templates
+func (s *Server) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"uuid": func() string {
+			return uuid.New().String()
+		},
+		"now": func(layout ...string) string {
+			if len(layout) > 0 {
+				return s.now().UTC().Format(layout[0])
+			}
+			return s.now().UTC().Format(time.RFC3339)
+		},
+		"randomInt": func(min, max int) (int, error) {
+			if max <= min {
+				return 0, fmt.Errorf("randomInt: max must be greater than min")
+			}
+			return min + s.random().Intn(max-min), nil
+		},
+		"base64": func(v string) string {
+			return base64.StdEncoding.EncodeToString([]byte(v))
+		},
+		"base64Decode": func(v string) (string, error) {
+			b, err := base64.StdEncoding.DecodeString(v)
+			return string(b), err
+		},
+		"jsonpath": jsonPath,
+		"toJSON": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		templateDeadlineFunc: func() string {
+			return ""
+		},
+	}
+}
+
+func (s *Server) parseResponseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(s.templateFuncs()).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := guardTemplates(tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// guardTemplates rejects ranges over constant integers larger than
+// maxTemplateRange and makes every range loop check the render deadline,
+// since loops that write nothing never reach limitedBuffer
+func guardTemplates(tmpl *template.Template) error {
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if err := guardNode(t.Tree, t.Tree.Root); err != nil {
+			return fmt.Errorf("template: %s: %v", t.Name(), err)
+		}
+	}
+	return nil
+}
+
+func guardNode(tree *parse.Tree, node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := guardNode(tree, child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return guardBranch(tree, &n.BranchNode)
+	case *parse.WithNode:
+		return guardBranch(tree, &n.BranchNode)
+	case *parse.RangeNode:
+		if count, ok := constantInt(n.Pipe); ok && count > maxTemplateRange {
+			return fmt.Errorf("range over %d is larger than %d", count, maxTemplateRange)
+		}
+		check := parse.NewIdentifier(templateDeadlineFunc).SetTree(tree).SetPos(n.Pos)
+		n.List.Nodes = append([]parse.Node{&parse.ActionNode{
+			NodeType: parse.NodeAction,
+			Pos:      n.Pos,
+			Line:     n.Line,
+			Pipe: &parse.PipeNode{
+				NodeType: parse.NodePipe,
+				Pos:      n.Pos,
+				Line:     n.Line,
+				Cmds:     []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{check}}},
+			},
+		}}, n.List.Nodes...)
+		return guardBranch(tree, &n.BranchNode)
+	}
+	return nil
+}
+
+func guardBranch(tree *parse.Tree, n *parse.BranchNode) error {
+	if err := guardNode(tree, n.List); err != nil {
+		return err
+	}
+	if n.ElseList != nil {
+		return guardNode(tree, n.ElseList)
+	}
+	return nil
+}
+
+// constantInt returns the value of a pipeline that is a single integer
+// constant
+func constantInt(pipe *parse.PipeNode) (int64, bool) {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return 0, false
+	}
+	number, ok := pipe.Cmds[0].Args[0].(*parse.NumberNode)
+	if !ok || !number.IsInt {
+		return 0, false
+	}
+	return number.Int64, true
+}
+
+// renderTemplate executes tmpl, failing once the output exceeds
+// maxTemplateOutput or rendering runs past maxTemplateDuration or the end
+// of ctx
+func renderTemplate(ctx context.Context, tmpl *template.Template, data *templateData) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, maxTemplateDuration)
+	defer cancel()
+
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(template.FuncMap{templateDeadlineFunc: func() (string, error) {
+		if ctx.Err() != nil {
+			return "", errTemplateTimeout
+		}
+		return "", nil
+	}})
+
+	out := &limitedBuffer{ctx: ctx, max: maxTemplateOutput}
+	if err := tmpl.Execute(out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+	ctx context.Context
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.ctx.Err() != nil {
+		return 0, errTemplateTimeout
+	}
+	if b.Len()+len(p) > b.max {
+		return 0, errTemplateOutputTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// jsonPath looks up a value by a path such as $.user.tags[0] or
+// user.tags.0, returning nil when it does not exist
+func jsonPath(v interface{}, path string) interface{} {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// handleTemplate renders the body template in the tmpl query parameter
+// when Config.AdhocTemplates is set. Each header parameter,
+// "Name: template", adds a rendered response header and content_type sets
+// the Content-Type.
+func (s *Server) handleTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.cfg().AdhocTemplates {
+			http.Error(w, "Ad-hoc templates are disabled, see -adhoc-templates", http.StatusForbidden)
+			return
+		}
+
+		query := r.URL.Query()
+		body, err := s.parseResponseTemplate("tmpl", query.Get("tmpl"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid template: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		headers := map[string]*template.Template{}
+		for _, header := range query["header"] {
+			parts := strings.SplitN(header, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+				http.Error(w, fmt.Sprintf("Invalid header %q, expected Name: template", header), http.StatusBadRequest)
+				return
+			}
+			name := http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))
+			if headers[name], err = s.parseResponseTemplate(name, strings.TrimSpace(parts[1])); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid template for header %s: %v", name, err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		contentType := query.Get("content_type")
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		data, err := newTemplateData(r, mux.Vars(r))
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		writeTemplate(w, r, http.StatusOK, contentType, data, body, headers)
+	}
+}
+
+// handleNamedTemplate renders a template loaded from
+// Config.ResponseTemplatesDir
+func (s *Server) handleNamedTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		var tmpl *template.Template
+		if s.responseTemplates != nil {
+			tmpl = s.responseTemplates.Lookup(name)
+		}
+		if tmpl == nil {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		data, err := newTemplateData(r, mux.Vars(r))
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		writeTemplate(w, r, http.StatusOK, contentType, data, tmpl, nil)
+	}
+}
+
+// writeTemplate renders the body and header templates and writes the
+// response, answering 400 if rendering fails
+func writeTemplate(w http.ResponseWriter, r *http.Request, code int, contentType string, data *templateData, body *template.Template, headers map[string]*template.Template) {
+	rendered, err := renderTemplate(r.Context(), body, data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unable to render template: %v", err), http.StatusBadRequest)
+		return
+	}
+	for name, tmpl := range headers {
+		val, err := renderTemplate(r.Context(), tmpl, data)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Unable to render header %s: %v", name, err), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set(name, string(val))
+	}
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(code)
+	w.Write(rendered)
+}
+
+// loadResponseTemplates parses every *.tmpl file in dir, naming each
+// template after its file name without the .tmpl suffix, e.g. user.json
+func (s *Server) loadResponseTemplates(dir string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	root := template.New("").Funcs(s.templateFuncs()).Option("missingkey=zero")
+	for _, file := range files {
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read response template: %v", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		if _, err := root.New(name).Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("unable to parse response template %s: %v", name, err)
+		}
+	}
+	if err := guardTemplates(root); err != nil {
+		return nil, fmt.Errorf("unable to parse response template: %v", err)
+	}
+	return root, nil
+}
@@ -0,0 +1,153 @@
+package httpbin
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var adhocTemplateServer = &Server{config: &Config{AdhocTemplates: true}}
+
+func TestHandleTemplate(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	server := newTestServer(t, WithConfig(&Config{AdhocTemplates: true}), withTestClock(&now))
+
+	testCases := []struct {
+		tmpl     string
+		body     string
+		expected string
+	}{
+		{`{{.Method}} {{.Arg "name"}} {{.Header "X-Test"}}`, "", "POST ann yes"},
+		{`{{.JSON.user.name}} {{jsonpath .JSON "$.user.tags[1]"}}`, `{"user":{"name":"bob","tags":["a","b"]}}`, "bob b"},
+		{`{{now}} {{now "2006"}}`, "", "2024-03-01T12:00:00Z 2024"},
+		{`{{base64 "hi"}} {{base64Decode "aGk="}}`, "", "aGk= hi"},
+		{`{{toJSON .Query.name}}`, "", `["ann"]`},
+		{`{{with randomInt 5 6}}{{.}}{{end}}`, "", "5"},
+	}
+	for _, tc := range testCases {
+		query := url.Values{"tmpl": {tc.tmpl}, "name": {"ann"}}
+		r := httptest.NewRequest("POST", "http://test.com/template?"+query.Encode(), strings.NewReader(tc.body))
+		r.Header.Set("X-Test", "yes")
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != tc.expected {
+			t.Errorf("Expected %q to render %q, got: %d %q", tc.tmpl, tc.expected, w.Code, w.Body)
+		}
+	}
+}
+
+func TestHandleTemplate_Headers(t *testing.T) {
+	query := url.Values{
+		"tmpl":         {`{"id": "{{.Arg "id"}}"}`},
+		"header":       {"X-Request: {{.Arg \"id\"}}-{{.Method}}"},
+		"content_type": {"application/json"},
+		"id":           {"42"},
+	}
+	target := "http://test.com/template?" + query.Encode()
+	req := newTestRequest(adhocTemplateServer.handleTemplate(), target, "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+	if val := req.parsedJSON.Path("id").String(); val != "42" {
+		t.Errorf("Expected id to be 42, got: %s", val)
+	}
+	if val := req.response.Header().Get("X-Request"); val != "42-GET" {
+		t.Errorf("Expected X-Request header to be 42-GET, got: %s", val)
+	}
+}
+
+func TestHandleTemplate_Invalid(t *testing.T) {
+	for _, tmpl := range []string{"{{.Method", `{{base64Decode "!"}}`, `{{range 10000}}{{range 200}}0123456789{{end}}{{end}}`, `{{range 300000000}}{{end}}ok`} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "http://test.com/template?"+url.Values{"tmpl": {tmpl}}.Encode(), nil)
+		adhocTemplateServer.handleTemplate()(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got: %d", tmpl, w.Code)
+		}
+	}
+}
+
+func TestHandleTemplate_Disabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://test.com/template?tmpl=ok", nil)
+	reqInspectServer.handleTemplate()(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected ad-hoc templates to be disabled by default, got: %d", w.Code)
+	}
+}
+
+func TestHandleTemplate_Runaway(t *testing.T) {
+	tmpl := `{{range 10000}}{{range 10000}}{{range 10000}}{{end}}{{end}}{{end}}ok`
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://test.com/template?"+url.Values{"tmpl": {tmpl}}.Encode(), nil)
+	adhocTemplateServer.handleTemplate()(w, r)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "took too long") {
+		t.Errorf("Expected the runaway template to be stopped, got: %d %s", w.Code, w.Body)
+	}
+	if elapsed := time.Since(start); elapsed > maxTemplateDuration+time.Second {
+		t.Errorf("Expected the template to stop after %v, took %v", maxTemplateDuration, elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	w = httptest.NewRecorder()
+	adhocTemplateServer.handleTemplate()(w, r.WithContext(ctx))
+	if elapsed := time.Since(start); w.Code != http.StatusBadRequest || elapsed > maxTemplateDuration/2 {
+		t.Errorf("Expected a cancelled request to stop rendering at once, got: %d after %v", w.Code, elapsed)
+	}
+}
+
+func TestHandleNamedTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "user.json.tmpl"), []byte(`{"id": "{{.Vars.name}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{ResponseTemplatesDir: dir}
+	server := newTestServer(t, WithConfig(config))
+
+	w := serve(server.Handler(), "GET", "/template/user.json", "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"id": "user.json"}` {
+		t.Errorf("Expected rendered template, got: %d %s", w.Code, w.Body)
+	}
+	if val := w.Header().Get("Content-Type"); val != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got: %s", val)
+	}
+
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest("GET", "http://test.com/template/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got: %d", w.Code)
+	}
+}
+
+func TestMocks_Template(t *testing.T) {
+	handler := newTestServer(t, WithConfig(&Config{Admin: true})).Handler()
+	createMock(t, handler, `{
+		"request": {"path": "/users/{id}"},
+		"response": {"template": true, "headers": {"X-User": "{{.Vars.id}}"}, "body": "{\"id\": \"{{.Vars.id}}\", \"q\": \"{{.Arg \"q\"}}\"}"}
+	}`)
+
+	w := serve(handler, "GET", "/users/7?q=x", "")
+	if w.Body.String() != `{"id": "7", "q": "x"}` || w.Header().Get("X-User") != "7" {
+		t.Errorf("Expected rendered mock response, got: %s %v", w.Body, w.Header())
+	}
+
+	if w := serve(handler, "POST", "/_admin/mocks", `{"request": {"path": "/a"}, "response": {"template": true, "body": "{{"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid template to be rejected, got: %d", w.Code)
+	}
+}
@@ -1,37 +1,135 @@
 package httpbin
 
+import "fmt"
+
+type routeGroup struct {
+	name string
+	init func()
+}
+
+// routeGroups lists the route groups that can be enabled through
+// Config.RouteGroups
+func (s *Server) routeGroups() []routeGroup {
+	return []routeGroup{
+		{"http", s.initHTTPRoutes},
+		{"anything", s.initAnythingRoutes},
+		{"status", s.initStatusCodeRoutes},
+		{"request-inspection", s.initRequestInspectionRoutes},
+		{"auth", s.initAuthRoutes},
+		{"response-inspection", s.initResponseInspectionRoutes},
+		{"response-formats", s.initResponseFormatRoutes},
+		{"dynamic-data", s.initDynamicDataRoutes},
+		{"cookies", s.initCookieRoutes},
+		{"images", s.initImageRoutes},
+		{"redirects", s.initRedirectRoutes},
+		{"tls", s.initTLSRoutes},
+		{"health", s.initHealthRoutes},
+		{"metrics", s.initMetricsRoutes},
+		{"bins", s.initBinRoutes},
+		{"templates", s.initTemplateRoutes},
+		{"websocket", s.initWebSocketRoutes},
+	}
+}
+
+func (s *Server) validateRouteGroups() error {
+	known := make(map[string]bool)
+	for _, group := range s.routeGroups() {
+		known[group.name] = true
+	}
+	for _, name := range s.cfg().RouteGroups {
+		if !known[name] {
+			return fmt.Errorf("unknown route group: %s", name)
+		}
+	}
+	return nil
+}
+
 func (s *Server) initRoutes() {
-	// HTTP Routes
+	cfg := s.cfg()
+	for _, group := range s.routeGroups() {
+		if cfg.routeGroupEnabled(group.name) {
+			group.init()
+		}
+	}
+}
+
+// initAdminRoutes registers the admin API and the routes it creates at
+// runtime ahead of the route groups, so mocks can override built-in
+// endpoints
+func (s *Server) initAdminRoutes() {
+	s.mocks = &mockStore{}
+
+	admin := s.router.PathPrefix("/_admin").Subrouter()
+	admin.HandleFunc("/mocks", s.handleCreateMock()).Methods("POST")
+	admin.HandleFunc("/mocks", s.handleListMocks()).Methods("GET")
+	admin.HandleFunc("/mocks", s.handleResetMocks()).Methods("DELETE")
+	admin.HandleFunc("/mocks/{id}", s.handleGetMock()).Methods("GET")
+	admin.HandleFunc("/mocks/{id}", s.handleDeleteMock()).Methods("DELETE")
+	admin.HandleFunc("/requests", s.handleMockRequests()).Methods("GET")
+	admin.HandleFunc("/verify", s.handleVerify()).Methods("POST")
+
+	s.router.MatcherFunc(s.matchMock).Handler(s.handleMock())
+}
+
+func (s *Server) initHTTPRoutes() {
 	s.router.HandleFunc("/delete", s.handleDelete()).Methods("DELETE")
 	s.router.HandleFunc("/get", s.handleGet()).Methods("GET")
 	s.router.HandleFunc("/patch", s.handlePatch()).Methods("PATCH")
 	s.router.HandleFunc("/post", s.handlePost()).Methods("POST")
 	s.router.HandleFunc("/put", s.handlePut()).Methods("PUT")
+}
 
-	// Anything Routes
+func (s *Server) initAnythingRoutes() {
 	s.router.HandleFunc("/anything", s.handleAnything())
 	s.router.HandleFunc("/anything/{anything}", s.handleAnything())
+}
 
-	// Status Code Routes
+func (s *Server) initStatusCodeRoutes() {
 	s.router.HandleFunc("/status/{codes}", s.handleStatusCodes())
+}
 
-	// Request Inspection Routes
+func (s *Server) initRequestInspectionRoutes() {
 	s.router.HandleFunc("/headers", s.handleHeaders()).Methods("GET")
 	s.router.HandleFunc("/ip", s.handleIP()).Methods("GET")
 	s.router.HandleFunc("/user-agent", s.handleUserAgent()).Methods("GET")
+}
 
-	// Auth Routes
+func (s *Server) initAuthRoutes() {
 	s.router.HandleFunc("/basic-auth/{user}/{password}", s.handleBasicAuth()).Methods("GET")
 	s.router.HandleFunc("/bearer", s.handleBearer()).Methods("GET")
-	s.router.HandleFunc("/hidden-basic-auth/{user}/{password}", s.handleBasicAuth()).Methods("GET")
+	s.router.HandleFunc("/api-key/{carrier}/{name}/{key}", s.handleAPIKey()).Methods("GET")
+	s.router.HandleFunc("/jwt", s.handleJWT()).Methods("GET", "POST")
+	s.router.HandleFunc("/jwt/issue", s.handleJWTIssue()).Methods("GET", "POST")
+	s.router.HandleFunc("/jwt/jwks", s.handleJWKS()).Methods("GET")
+	s.router.HandleFunc("/.well-known/openid-configuration", s.handleOpenIDConfiguration()).Methods("GET")
+	s.router.HandleFunc("/.well-known/jwks.json", s.handleJWKS()).Methods("GET")
+	s.router.HandleFunc("/oauth/authorize", s.handleOAuthAuthorize()).Methods("GET")
+	s.router.HandleFunc("/oauth/token", s.handleOAuthToken()).Methods("POST")
+	s.router.HandleFunc("/oauth/device_authorization", s.handleOAuthDeviceAuthorization()).Methods("POST")
+	s.router.HandleFunc("/oauth/device", s.handleOAuthDevice()).Methods("GET", "POST")
+	s.router.HandleFunc("/oauth/introspect", s.handleOAuthIntrospect()).Methods("POST")
+	s.router.HandleFunc("/oauth/revoke", s.handleOAuthRevoke()).Methods("POST")
+	s.router.HandleFunc("/oauth/userinfo", s.handleOAuthUserinfo()).Methods("GET", "POST")
+	s.router.HandleFunc("/sigv4/{access_key}/{secret_key}", s.handleSigV4())
+	s.router.HandleFunc("/sigv4/{access_key}/{secret_key}/{path:.*}", s.handleSigV4())
+	s.router.HandleFunc("/http-signature/{key_id}/{secret}", s.handleHTTPSignature())
+	s.router.HandleFunc("/webhook/github/{secret}", s.handleWebhookHMAC("github", webhookHMAC{header: "X-Hub-Signature-256", prefix: "sha256=", algorithm: "sha256", encoding: "hex"}, true)).Methods("POST")
+	s.router.HandleFunc("/webhook/stripe/{secret}", s.handleStripeWebhook()).Methods("POST")
+	s.router.HandleFunc("/webhook/hmac/{secret}", s.handleWebhookHMAC("hmac", webhookHMAC{header: "X-Signature", algorithm: "sha256", encoding: "hex"}, false)).Methods("POST")
+	s.router.HandleFunc("/hidden-basic-auth/{user}/{password}", s.handleHiddenBasicAuth()).Methods("GET")
+	s.router.HandleFunc("/digest-auth/{qop}/{user}/{password}", s.handleDigestAuth())
+	s.router.HandleFunc("/digest-auth/{qop}/{user}/{password}/{algorithm}", s.handleDigestAuth())
+	s.router.HandleFunc("/digest-auth/{qop}/{user}/{password}/{algorithm}/{stale_after}", s.handleDigestAuth())
+}
 
-	// Response Inspection Routes
+func (s *Server) initResponseInspectionRoutes() {
 	s.router.HandleFunc("/cache", s.handleCache()).Methods("GET")
 	s.router.HandleFunc("/cache/{value:[0-9]+}", s.handleCacheControl()).Methods("GET")
 	s.router.HandleFunc("/etag/{etag}", s.handleETag()).Methods("GET")
 	s.router.HandleFunc("/response-headers", s.handleResponseHeaders()).Methods("GET", "POST")
+}
 
-	// Response Formats
+func (s *Server) initResponseFormatRoutes() {
 	s.router.HandleFunc("/deny", s.handleDeny()).Methods("GET")
 	s.router.HandleFunc("/encoding/utf8", s.handleEncodingUTF8()).Methods("GET")
 	s.router.HandleFunc("/html", s.handleHTML()).Methods("GET")
@@ -39,8 +137,9 @@ func (s *Server) initRoutes() {
 	s.router.HandleFunc("/robots.txt", s.handleRobotsTxt()).Methods("GET")
 	s.router.HandleFunc("/xml", s.handleXML()).Methods("GET")
 	s.router.HandleFunc("/gzip", s.handleGzip()).Methods("GET")
+}
 
-	// Dynamic Data
+func (s *Server) initDynamicDataRoutes() {
 	s.router.HandleFunc("/base64/{value}", s.handleBase64Decode()).Methods("GET")
 	s.router.HandleFunc("/bytes/{n:[0-9]+}", s.handleBytes()).Methods("GET")
 	s.router.HandleFunc("/delay/{delay:[0-9]+}", s.handleDelay())
@@ -50,20 +149,60 @@ func (s *Server) initRoutes() {
 	s.router.HandleFunc("/stream-bytes/{n:[0-9]+}", s.handleStreamBytes()).Methods("GET")
 	s.router.HandleFunc("/stream/{n:[0-9]+}", s.handleStream()).Methods("GET")
 	s.router.HandleFunc("/uuid", s.handleUUID()).Methods("GET")
+}
 
-	// Cookies
+func (s *Server) initCookieRoutes() {
 	s.router.HandleFunc("/cookies", s.handleCookies()).Methods("GET")
 	s.router.HandleFunc("/cookies/delete", s.handleCookiesDelete()).Methods("GET")
 	s.router.HandleFunc("/cookies/set", s.handleCookiesSet()).Methods("GET")
 	s.router.HandleFunc("/cookies/set/{name}/{value}", s.handleCookiesSet()).Methods("GET")
+}
 
-	// Images
+func (s *Server) initImageRoutes() {
 	s.router.HandleFunc("/image", s.handleImage("")).Methods("GET")
 	s.router.HandleFunc("/image/jpeg", s.handleImage("image/jpeg")).Methods("GET")
 	s.router.HandleFunc("/image/png", s.handleImage("image/png")).Methods("GET")
 	s.router.HandleFunc("/image/svg", s.handleImage("image/svg+xml")).Methods("GET")
 	s.router.HandleFunc("/image/webp", s.handleImage("image/webp")).Methods("GET")
+}
 
-	// Redirects
+func (s *Server) initRedirectRoutes() {
 	s.router.HandleFunc("/redirect-to", s.handleRedirectTo())
+	s.router.HandleFunc("/redirect/{n}", s.handleRedirect())
+	s.router.HandleFunc("/relative-redirect/{n}", s.handleRelativeRedirect())
+	s.router.HandleFunc("/absolute-redirect/{n}", s.handleAbsoluteRedirect())
+	s.router.HandleFunc("/redirect-loop", s.handleRedirectLoop())
+	s.router.HandleFunc("/cross-host-redirect", s.handleCrossHostRedirect())
+	s.router.HandleFunc("/cross-scheme-redirect", s.handleCrossSchemeRedirect())
+	s.router.HandleFunc("/redirect-method/{code}", s.handleRedirectMethod())
+}
+
+func (s *Server) initTLSRoutes() {
+	s.router.HandleFunc("/tls", s.handleTLS()).Methods("GET")
+}
+
+func (s *Server) initHealthRoutes() {
+	s.router.HandleFunc("/healthz", s.handleHealthz()).Methods("GET")
+	s.router.HandleFunc("/readyz", s.handleReadyz()).Methods("GET")
+}
+
+func (s *Server) initMetricsRoutes() {
+	s.router.HandleFunc("/metrics", s.handleMetrics()).Methods("GET")
+}
+
+func (s *Server) initBinRoutes() {
+	s.router.HandleFunc("/bins", s.handleCreateBin()).Methods("POST")
+	s.router.HandleFunc("/bins/{id}/requests", s.handleBinRequests()).Methods("GET")
+	s.router.HandleFunc("/bins/{id}", s.handleBinCapture())
+	s.router.HandleFunc("/bins/{id}/{path:.*}", s.handleBinCapture())
+}
+
+func (s *Server) initTemplateRoutes() {
+	s.router.HandleFunc("/template", s.handleTemplate())
+	s.router.HandleFunc("/template/{name}", s.handleNamedTemplate())
+}
+
+func (s *Server) initWebSocketRoutes() {
+	s.router.HandleFunc("/ws/echo", s.handleWebSocketEcho()).Methods("GET")
+	s.router.HandleFunc("/ws/stream/{n:[0-9]+}", s.handleWebSocketStream()).Methods("GET")
 }
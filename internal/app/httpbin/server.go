@@ -1,27 +1,344 @@
 package httpbin
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // Server represents the server
 type Server struct {
-	router *mux.Router
+	router   *mux.Router
+	root     *mux.Router
+	config   *Config
+	assetSet *assetSet
+	clock    func() time.Time
+	rand     *lockedRand
+	logger   *log.Logger
+
+	accessLogger *accessLogger
+	accessLogOut io.Writer
+	overrides    []func(*Config)
+	metrics      *metrics
+	bins         binStore
+	mocks        *mockStore
+	jwtKeySet    *jwtKeySet
+
+	responseTemplates *template.Template
+	statusSequences   statusSequences
+	digestNonces      digestNonces
+	oauth             oauthStore
+	hijacked          hijackedConns
+
+	// draining is set once graceful shutdown has begun
+	draining int32
+}
+
+// Option configures optional server behaviour
+type Option func(*Server)
+
+// WithConfig replaces the server configuration with a copy of config.
+// Options that set a single field, such as WithMultiValue, apply on top of
+// it whatever their order.
+func WithConfig(config *Config) Option {
+	return func(s *Server) {
+		s.config = config.clone()
+	}
+}
+
+// WithMultiValue emits args, form fields and headers as a string when
+// single-valued and as an array when repeated, instead of joining repeated
+// values with commas
+func WithMultiValue() Option {
+	return func(s *Server) {
+		s.overrides = append(s.overrides, func(c *Config) { c.MultiValue = true })
+	}
+}
+
+// WithPathPrefix mounts all routes under prefix, see Config.BasePath
+func WithPathPrefix(prefix string) Option {
+	return func(s *Server) {
+		s.overrides = append(s.overrides, func(c *Config) { c.BasePath = prefix })
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = now
+	}
+}
+
+// WithSeed seeds the random source used for random status codes and bytes
+// so responses are reproducible
+func WithSeed(seed int64) Option {
+	return func(s *Server) {
+		s.rand = newLockedRand(seed)
+	}
+}
+
+// WithLogger sets the logger for server messages and errors
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithAccessLogOutput sets where access logs are written, overriding
+// Config.AccessLogFile
+func WithAccessLogOutput(w io.Writer) Option {
+	return func(s *Server) {
+		s.accessLogOut = w
+	}
 }
 
 // NewServer builds and returns a new server
-func NewServer(router *mux.Router) (*Server, error) {
+func NewServer(router *mux.Router, opts ...Option) (*Server, error) {
 	server := &Server{
 		router: router,
+		root:   router,
+		config: DefaultConfig(),
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	for _, override := range server.overrides {
+		override(server.config)
+	}
+	if err := server.initAccessLog(); err != nil {
+		return nil, err
+	}
+	if server.cfg().routeGroupEnabled("metrics") {
+		server.metrics = newMetrics()
+	}
+	if cfg := server.cfg(); cfg.routeGroupEnabled("bins") {
+		bins, err := newBinStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		server.bins = bins
+	}
+	if basePath := server.basePath(); basePath != "" {
+		server.router = router.PathPrefix(basePath).Subrouter()
+	}
+	if err := server.validateRouteGroups(); err != nil {
+		return nil, err
+	}
+	if cfg := server.cfg(); cfg.TemplatesDir != "" || cfg.ImagesDir != "" {
+		assetSet, err := loadAssets(cfg.TemplatesDir, cfg.ImagesDir)
+		if err != nil {
+			return nil, err
+		}
+		server.assetSet = assetSet
+	}
+	if dir := server.cfg().ResponseTemplatesDir; dir != "" {
+		tmpl, err := server.loadResponseTemplates(dir)
+		if err != nil {
+			return nil, err
+		}
+		server.responseTemplates = tmpl
+	}
+	if file := server.cfg().JWKSFile; file != "" {
+		keys, err := loadJWKS(file)
+		if err != nil {
+			return nil, err
+		}
+		server.jwtKeySet = keys
+	}
+	if server.cfg().Admin {
+		server.initAdminRoutes()
 	}
 	server.initRoutes()
 	return server, nil
 }
 
-// ListenAndServe starts the http listener
-func (s *Server) ListenAndServe() {
-	log.Fatal(http.ListenAndServe("0.0.0.0:8080", s.router))
+// ListenAndServe starts the http listener and blocks until it fails or a
+// SIGINT or SIGTERM has been received and in-flight requests have drained
+func (s *Server) ListenAndServe() error {
+	srv, err := s.httpServer()
+	if err != nil {
+		return fmt.Errorf("unable to configure server: %v", err)
+	}
+
+	l, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	return s.serve(srv, l, stop)
+}
+
+// serve accepts connections on l until stop receives a signal, then marks
+// the server as not ready, waits for the shutdown delay and gives in-flight
+// requests, including HTTP/2 streams and hijacked connections such as
+// WebSockets, up to the drain timeout to complete
+func (s *Server) serve(srv *http.Server, l net.Listener, stop <-chan os.Signal) error {
+	errs := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errs <- srv.ServeTLS(l, "", "")
+		} else {
+			errs <- srv.Serve(l)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		s.logf("Received %v, draining connections", sig)
+	}
+
+	atomic.StoreInt32(&s.draining, 1)
+	cfg := s.cfg()
+	time.Sleep(time.Duration(cfg.ShutdownDelay))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.DrainTimeout))
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to drain connections: %v", err)
+	}
+	if err := s.hijacked.wait(ctx); err != nil {
+		return fmt.Errorf("failed to drain hijacked connections: %v", err)
+	}
+	return nil
+}
+
+// Handler returns the server's routes wrapped in its middleware
+func (s *Server) Handler() http.Handler {
+	root := s.root
+	if root == nil {
+		root = s.router
+	}
+	return s.upgradeH2C(s.logAccess(s.collectMetrics(s.trackConnections(s.injectFaults(s.limitBody(root))))))
+}
+
+func (s *Server) initAccessLog() error {
+	cfg := s.cfg()
+	if cfg.AccessLog == "" || cfg.AccessLog == AccessLogNone {
+		return nil
+	}
+
+	out := s.accessLogOut
+	if out == nil {
+		switch cfg.AccessLogFile {
+		case "", "-":
+			out = os.Stdout
+		default:
+			f, err := os.OpenFile(cfg.AccessLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return fmt.Errorf("unable to open access log: %v", err)
+			}
+			out = f
+		}
+	}
+
+	logger, err := newAccessLogger(cfg.AccessLog, out)
+	if err != nil {
+		return err
+	}
+	s.accessLogger = logger
+	return nil
+}
+
+// httpServer builds the http.Server described by the server configuration
+func (s *Server) httpServer() (*http.Server, error) {
+	cfg := s.cfg()
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      s.Handler(),
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+		ConnContext:  withConnInfo,
+		ErrorLog:     s.logger,
+	}
+
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	if cfg.HTTP2 {
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(!cfg.tlsEnabled())
+	}
+
+	if cfg.tlsEnabled() {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure TLS: %v", err)
+		}
+		srv.TLSConfig = tlsConfig
+	}
+
+	return srv, nil
+}
+
+// cfg returns the server configuration, falling back to the defaults for
+// servers that weren't built with NewServer
+func (s *Server) cfg() *Config {
+	if s.config == nil {
+		return DefaultConfig()
+	}
+	return s.config
+}
+
+// basePath returns the configured base path without a trailing slash
+func (s *Server) basePath() string {
+	basePath := strings.TrimSuffix(s.cfg().BasePath, "/")
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+	return basePath
+}
+
+// path returns the URL path of a route, including the base path
+func (s *Server) path(p string) string {
+	return s.basePath() + p
+}
+
+// now returns the current time from the server's clock
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+	s.logger.Printf(format, v...)
+}
+
+// limitBody rejects request bodies larger than the configured maximum
+func (s *Server) limitBody(next http.Handler) http.Handler {
+	maxBodySize := s.cfg().MaxBodySize
+	if maxBodySize <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBodySize {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,634 @@
+package httpbin
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// sigV4MaxSkew is how far the X-Amz-Date of a signed request may be
+	// from the server time, as enforced by AWS
+	sigV4MaxSkew = 15 * time.Minute
+
+	// defaultStripeTolerance is how old a Stripe signature timestamp may be
+	defaultStripeTolerance = 5 * time.Minute
+)
+
+var hmacHashes = map[string]func() hash.Hash{"sha1": sha1.New, "sha256": sha256.New, "sha512": sha512.New}
+
+// signatureResponse explains the verification of a signed request: the
+// exact string that was signed and the signature expected for it
+type signatureResponse struct {
+	Authenticated    bool   `json:"authenticated"`
+	Scheme           string `json:"scheme"`
+	KeyID            string `json:"key_id,omitempty"`
+	CanonicalRequest string `json:"canonical_request,omitempty"`
+	StringToSign     string `json:"string_to_sign,omitempty"`
+	Expected         string `json:"expected_signature,omitempty"`
+	Received         string `json:"received_signature,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+func writeSignature(w http.ResponseWriter, resp *signatureResponse, err error) {
+	if err != nil {
+		resp.Error = err.Error()
+		writeJSON(w, http.StatusUnauthorized, resp)
+		return
+	}
+	resp.Authenticated = true
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSigV4 verifies an AWS Signature Version 4 signed request, from
+// either the Authorization header or presigned URL parameters, against the
+// access key and secret key in the path
+func (s *Server) handleSigV4() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := &signatureResponse{Scheme: "aws-sigv4", KeyID: vars["access_key"]}
+		writeSignature(w, resp, s.verifySigV4(r, body, vars["access_key"], vars["secret_key"], resp))
+	}
+}
+
+func (s *Server) verifySigV4(r *http.Request, body []byte, accessKey, secretKey string, resp *signatureResponse) error {
+	query := r.URL.Query()
+	var credential, signedHeaders, signature, amzDate string
+	presigned := false
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		params := make(map[string]string)
+		for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) == 2 {
+				params[kv[0]] = kv[1]
+			}
+		}
+		credential, signedHeaders, signature = params["Credential"], params["SignedHeaders"], params["Signature"]
+		amzDate = r.Header.Get("X-Amz-Date")
+	} else if query.Get("X-Amz-Algorithm") == "AWS4-HMAC-SHA256" {
+		credential, signedHeaders, signature = query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"), query.Get("X-Amz-Signature")
+		amzDate = query.Get("X-Amz-Date")
+		presigned = true
+	} else {
+		return fmt.Errorf("missing AWS4-HMAC-SHA256 Authorization header or X-Amz-Algorithm query parameter")
+	}
+	resp.Received = signature
+
+	scope := strings.SplitN(credential, "/", 2)
+	if len(scope) != 2 || len(strings.Split(scope[1], "/")) != 4 || !strings.HasSuffix(scope[1], "/aws4_request") {
+		return fmt.Errorf("credential %q must be <access key>/<date>/<region>/<service>/aws4_request", credential)
+	}
+	if scope[0] != accessKey {
+		return fmt.Errorf("access key %q does not match %q", scope[0], accessKey)
+	}
+	if signedHeaders == "" || signature == "" {
+		return fmt.Errorf("missing SignedHeaders or Signature")
+	}
+	signed, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("invalid X-Amz-Date %q, expected e.g. 20150830T123600Z", amzDate)
+	}
+
+	var headers strings.Builder
+	names := strings.Split(signedHeaders, ";")
+	for _, name := range names {
+		var vals []string
+		if name == "host" {
+			vals = []string{r.Host}
+		} else {
+			vals = r.Header.Values(name)
+		}
+		if len(vals) == 0 {
+			return fmt.Errorf("signed header %q is missing from the request", name)
+		}
+		for i, val := range vals {
+			vals[i] = strings.Join(strings.Fields(val), " ")
+		}
+		fmt.Fprintf(&headers, "%s:%s\n", name, strings.Join(vals, ","))
+	}
+	if !contains(names, "host") {
+		return fmt.Errorf("SignedHeaders must include host")
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	switch {
+	case payloadHash == "" && presigned:
+		payloadHash = "UNSIGNED-PAYLOAD"
+	case payloadHash == "":
+		payloadHash = hexSHA256(body)
+	case len(payloadHash) == sha256.Size*2 && payloadHash != hexSHA256(body):
+		return fmt.Errorf("X-Amz-Content-Sha256 %s does not match the body hash %s", payloadHash, hexSHA256(body))
+	}
+
+	resp.CanonicalRequest = strings.Join([]string{
+		r.Method,
+		sigV4CanonicalURI(r.URL.Path),
+		sigV4CanonicalQuery(query),
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	resp.StringToSign = strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope[1], hexSHA256([]byte(resp.CanonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range strings.Split(scope[1], "/") {
+		key = hmacSum(sha256.New, key, []byte(part))
+	}
+	resp.Expected = hex.EncodeToString(hmacSum(sha256.New, key, []byte(resp.StringToSign)))
+	if !hmac.Equal([]byte(resp.Expected), []byte(signature)) {
+		return fmt.Errorf("the signature calculated for the string to sign does not match the signature provided")
+	}
+
+	if signed.Format("20060102") != strings.Split(scope[1], "/")[0] {
+		return fmt.Errorf("credential date does not match X-Amz-Date %s", amzDate)
+	}
+	now := s.now()
+	if presigned {
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires < 1 || expires > 604800 {
+			return fmt.Errorf("X-Amz-Expires must be between 1 and 604800 seconds")
+		}
+		if expiry := signed.Add(time.Duration(expires) * time.Second); !now.Before(expiry) {
+			return fmt.Errorf("presigned URL expired at %s", expiry.Format(time.RFC3339))
+		}
+	} else if skew := now.Sub(signed); skew > sigV4MaxSkew || skew < -sigV4MaxSkew {
+		return fmt.Errorf("X-Amz-Date %s is more than %v from the server time %s", amzDate, sigV4MaxSkew, now.UTC().Format("20060102T150405Z"))
+	}
+	return nil
+}
+
+// sigV4CanonicalURI URI-encodes each segment of the path
+func sigV4CanonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// sigV4CanonicalQuery URI-encodes and sorts the query parameters, without
+// the signature of a presigned URL
+func sigV4CanonicalQuery(query url.Values) string {
+	var params [][2]string
+	for name, vals := range query {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, val := range vals {
+			params = append(params, [2]string{awsURIEncode(name), awsURIEncode(val)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+	pairs := make([]string, len(params))
+	for i, param := range params {
+		pairs[i] = param[0] + "=" + param[1]
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes every byte but the unreserved characters
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// handleHTTPSignature verifies an RFC 9421 HTTP Message Signature made
+// with hmac-sha256 and the key in the path. ?label= selects the signature
+// when several were sent.
+func (s *Server) handleHTTPSignature() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := &signatureResponse{Scheme: "http-message-signatures", KeyID: vars["key_id"]}
+		writeSignature(w, resp, s.verifyHTTPSignature(r, body, vars["key_id"], vars["secret"], resp))
+	}
+}
+
+func (s *Server) verifyHTTPSignature(r *http.Request, body []byte, keyID, secret string, resp *signatureResponse) error {
+	inputs := parseSFDictionary(r.Header.Get("Signature-Input"))
+	signatures := parseSFDictionary(r.Header.Get("Signature"))
+	if len(inputs) == 0 || len(signatures) == 0 {
+		return fmt.Errorf("missing Signature-Input or Signature header")
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = inputs[0].name
+	}
+	input, ok := findSFMember(inputs, label)
+	if !ok {
+		return fmt.Errorf("no Signature-Input labelled %q", label)
+	}
+	signature, ok := findSFMember(signatures, label)
+	if !ok {
+		return fmt.Errorf("no Signature labelled %q", label)
+	}
+	resp.Received = signature
+	sig, err := base64.StdEncoding.DecodeString(strings.Trim(signature, ":"))
+	if err != nil || !strings.HasPrefix(signature, ":") || !strings.HasSuffix(signature, ":") {
+		return fmt.Errorf("signature %s must be a base64 byte sequence between colons", label)
+	}
+
+	end := closingParen(input)
+	if !strings.HasPrefix(input, "(") || end < 0 {
+		return fmt.Errorf("Signature-Input %s must start with a list of covered components", label)
+	}
+	params := parseSFParams(input[end+1:])
+
+	var base strings.Builder
+	for _, item := range splitSFItems(input[1:end]) {
+		name, itemParams := parseSFItem(item)
+		val, err := httpSignatureComponent(r, name, itemParams)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&base, "%s: %s\n", item, val)
+		if name == "content-digest" {
+			if err := checkContentDigest(val, body); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(&base, "\"@signature-params\": %s", input)
+	resp.StringToSign = base.String()
+
+	if alg, ok := params["alg"]; ok && alg != "hmac-sha256" {
+		return fmt.Errorf("alg %q is not supported, expected hmac-sha256", alg)
+	}
+	if id, ok := params["keyid"]; ok && id != keyID {
+		return fmt.Errorf("keyid %q does not match %q", id, keyID)
+	}
+
+	expected := hmacSum(sha256.New, []byte(secret), []byte(resp.StringToSign))
+	resp.Expected = ":" + base64.StdEncoding.EncodeToString(expected) + ":"
+	if !hmac.Equal(expected, sig) {
+		return fmt.Errorf("the signature calculated for the signature base does not match signature %s", label)
+	}
+
+	if val, ok := params["expires"]; ok {
+		secs, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid expires %q", val)
+		}
+		if expires := time.Unix(secs, 0).UTC(); !s.now().Before(expires) {
+			return fmt.Errorf("signature expired at %s", expires.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// httpSignatureComponent returns the value of a covered component
+func httpSignatureComponent(r *http.Request, name string, params map[string]string) (string, error) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@target-uri":
+		return scheme + "://" + r.Host + r.URL.RequestURI(), nil
+	case "@authority":
+		return strings.ToLower(r.Host), nil
+	case "@scheme":
+		return scheme, nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		return r.URL.EscapedPath(), nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	case "@query-param":
+		vals, ok := r.URL.Query()[params["name"]]
+		if !ok {
+			return "", fmt.Errorf("covered query parameter %q is missing from the request", params["name"])
+		}
+		return strings.Replace(url.QueryEscape(vals[0]), "+", "%20", -1), nil
+	}
+	if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("derived component %q is not supported", name)
+	}
+	if len(params) > 0 {
+		return "", fmt.Errorf("parameters of component %q are not supported", name)
+	}
+	vals := r.Header.Values(name)
+	if len(vals) == 0 {
+		return "", fmt.Errorf("covered header %q is missing from the request", name)
+	}
+	for i, val := range vals {
+		vals[i] = strings.TrimSpace(val)
+	}
+	return strings.Join(vals, ", "), nil
+}
+
+// checkContentDigest checks the sha-256 and sha-512 digests of an RFC 9530
+// Content-Digest header against the body
+func checkContentDigest(header string, body []byte) error {
+	for _, member := range parseSFDictionary(header) {
+		var sum []byte
+		switch member.name {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+		if expected := ":" + base64.StdEncoding.EncodeToString(sum) + ":"; member.value != expected {
+			return fmt.Errorf("Content-Digest %s=%s does not match the body digest %s", member.name, member.value, expected)
+		}
+	}
+	return nil
+}
+
+type sfMember struct {
+	name  string
+	value string
+}
+
+// parseSFDictionary splits a structured field dictionary into its members,
+// keeping each value as it was serialized
+func parseSFDictionary(header string) []sfMember {
+	var members []sfMember
+	for _, part := range splitSF(header, ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		member := sfMember{name: kv[0]}
+		if len(kv) == 2 {
+			member.value = kv[1]
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+func findSFMember(members []sfMember, name string) (string, bool) {
+	for _, m := range members {
+		if m.name == name {
+			return m.value, true
+		}
+	}
+	return "", false
+}
+
+// splitSFItems splits the items of an inner list on spaces
+func splitSFItems(list string) []string {
+	var items []string
+	for _, item := range splitSF(list, ' ') {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSFItem returns the string and parameters of a serialized item such
+// as "@query-param";name="id"
+func parseSFItem(item string) (string, map[string]string) {
+	parts := splitSF(item, ';')
+	return unquoteSF(parts[0]), parseSFParams(strings.Join(parts[1:], ";"))
+}
+
+// parseSFParams parses ;key=value parameters
+func parseSFParams(params string) map[string]string {
+	parsed := make(map[string]string)
+	for _, param := range splitSF(params, ';') {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if kv[0] == "" {
+			continue
+		}
+		if len(kv) == 1 {
+			parsed[kv[0]] = "?1"
+			continue
+		}
+		parsed[kv[0]] = unquoteSF(kv[1])
+	}
+	return parsed
+}
+
+// splitSF splits on sep outside of quoted strings and inner lists
+func splitSF(s string, sep byte) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func closingParen(s string) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ')':
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteSF(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// webhookHMAC describes a webhook signature header holding an HMAC of the
+// body
+type webhookHMAC struct {
+	header    string
+	prefix    string
+	algorithm string
+	encoding  string
+}
+
+// handleWebhookHMAC verifies a webhook signature header with the secret in
+// the path. Unless fixed, as for GitHub, the header, prefix, algorithm and
+// encoding can be changed through the query.
+func (s *Server) handleWebhookHMAC(scheme string, defaults webhookHMAC, fixed bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		spec := defaults
+		if !fixed {
+			query := r.URL.Query()
+			for param, field := range map[string]*string{
+				"header": &spec.header, "prefix": &spec.prefix, "algorithm": &spec.algorithm, "encoding": &spec.encoding,
+			} {
+				if val, ok := query[param]; ok {
+					*field = val[0]
+				}
+			}
+		}
+		newHash, ok := hmacHashes[spec.algorithm]
+		if !ok || (spec.encoding != "hex" && spec.encoding != "base64") {
+			http.Error(w, fmt.Sprintf("invalid algorithm %q or encoding %q, expected sha1, sha256 or sha512 and hex or base64", spec.algorithm, spec.encoding), http.StatusBadRequest)
+			return
+		}
+
+		resp := &signatureResponse{Scheme: scheme, StringToSign: string(body)}
+		sum := hmacSum(newHash, []byte(mux.Vars(r)["secret"]), body)
+		resp.Expected = spec.prefix + hex.EncodeToString(sum)
+		if spec.encoding == "base64" {
+			resp.Expected = spec.prefix + base64.StdEncoding.EncodeToString(sum)
+		}
+
+		resp.Received = r.Header.Get(spec.header)
+		switch {
+		case resp.Received == "":
+			err = fmt.Errorf("missing %s header", spec.header)
+		case !strings.HasPrefix(resp.Received, spec.prefix):
+			err = fmt.Errorf("%s header must start with %q", spec.header, spec.prefix)
+		case !hmac.Equal([]byte(resp.Received), []byte(resp.Expected)):
+			err = fmt.Errorf("%s does not match the HMAC-%s of the body", spec.header, strings.ToUpper(spec.algorithm))
+		}
+		writeSignature(w, resp, err)
+	}
+}
+
+// handleStripeWebhook verifies a Stripe-Signature header, an HMAC-SHA256
+// of the timestamp and body, with the secret in the path. Timestamps older
+// than ?tolerance= seconds, 300 by default and 0 for no limit, are
+// rejected.
+func (s *Server) handleStripeWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tolerance := defaultStripeTolerance
+		if val := r.URL.Query().Get("tolerance"); val != "" {
+			if tolerance, err = parseSeconds(val); err != nil || tolerance < 0 {
+				http.Error(w, fmt.Sprintf("invalid tolerance %q", val), http.StatusBadRequest)
+				return
+			}
+		}
+
+		resp := &signatureResponse{Scheme: "stripe"}
+		writeSignature(w, resp, s.verifyStripeSignature(r, body, mux.Vars(r)["secret"], tolerance, resp))
+	}
+}
+
+func (s *Server) verifyStripeSignature(r *http.Request, body []byte, secret string, tolerance time.Duration, resp *signatureResponse) error {
+	resp.Received = r.Header.Get("Stripe-Signature")
+	if resp.Received == "" {
+		return fmt.Errorf("missing Stripe-Signature header")
+	}
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(resp.Received, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Stripe-Signature must have a t=<unix timestamp> element")
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("Stripe-Signature must have a v1=<signature> element")
+	}
+
+	resp.StringToSign = timestamp + "." + string(body)
+	resp.Expected = hex.EncodeToString(hmacSum(sha256.New, []byte(secret), []byte(resp.StringToSign)))
+	matched := false
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(resp.Expected)) {
+			matched = true
+		}
+	}
+	if !matched {
+		return fmt.Errorf("no v1 signature matches the HMAC-SHA256 of <t>.<body>")
+	}
+
+	signed := time.Unix(secs, 0).UTC()
+	if age := s.now().Sub(signed); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return fmt.Errorf("timestamp %s is outside the tolerance of %v", signed.Format(time.RFC3339), tolerance)
+	}
+	return nil
+}
+
+func hmacSum(newHash func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,159 @@
+package httpbin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySigV4(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	s := &Server{clock: func() time.Time { return time.Date(2015, 8, 30, 12, 40, 0, 0, time.UTC) }}
+	r := httptest.NewRequest("GET", "http://example.amazonaws.com/", nil)
+	r.Header.Set("X-Amz-Date", "20150830T123600Z")
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31")
+
+	var resp signatureResponse
+	if err := s.verifySigV4(r, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", &resp); err != nil {
+		t.Fatalf("Expected the test suite signature to verify. Err: %v, Canonical request: %q", err, resp.CanonicalRequest)
+	}
+	expected := "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\nbb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63"
+	if resp.StringToSign != expected {
+		t.Errorf("Expected string to sign %q, got %q", expected, resp.StringToSign)
+	}
+
+	s.clock = func() time.Time { return time.Date(2015, 8, 30, 13, 0, 0, 0, time.UTC) }
+	if err := s.verifySigV4(r, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", &resp); err == nil || !strings.Contains(err.Error(), "more than 15m0s") {
+		t.Errorf("Expected a skewed request to be rejected, got %v", err)
+	}
+
+	if got := sigV4CanonicalQuery(map[string][]string{"a": {"2", "1"}, "a-b": {"x y"}}); got != "a=1&a=2&a-b=x%20y" {
+		t.Errorf("Unexpected canonical query %q", got)
+	}
+}
+
+func TestHandleSigV4(t *testing.T) {
+	handler := newTestServer(t, jwtTestClock).Handler()
+	target := "/sigv4/AKID/secret/bucket/key?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKID%2F20200101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Date=20200101T115900Z&X-Amz-Expires=300&X-Amz-SignedHeaders=host"
+
+	var resp signatureResponse
+	w := serve(handler, "GET", target+"&X-Amz-Signature=bad", "")
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(resp.CanonicalRequest, "GET\n/sigv4/AKID/secret/bucket/key\nX-Amz-Algorithm=") || resp.Expected == "" {
+		t.Fatalf("Expected the canonical request and signature to be explained. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	w = serve(handler, "GET", target+"&X-Amz-Signature="+resp.Expected, "")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the presigned URL to verify. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleHTTPSignature(t *testing.T) {
+	now := time.Unix(1618884473, 0)
+	handler := newTestServer(t, withTestClock(&now)).Handler()
+
+	sign := func(r *http.Request, input string) {
+		base := "\"date\": Tue, 20 Apr 2021 02:07:55 GMT\n\"@authority\": example.com\n\"content-type\": application/json\n\"@signature-params\": " + input
+		mac := hmac.New(sha256.New, []byte("shared-secret"))
+		mac.Write([]byte(base))
+		r.Header.Set("Signature-Input", "sig-b25="+input)
+		r.Header.Set("Signature", "sig-b25=:"+base64.StdEncoding.EncodeToString(mac.Sum(nil))+":")
+	}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("POST", "http://example.com/http-signature/test-key/shared-secret", strings.NewReader(`{"hello": "world"}`))
+		r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+		r.Header.Set("Content-Type", "application/json")
+		return r
+	}
+
+	testCases := []struct {
+		input string
+		err   string
+	}{
+		{`("date" "@authority" "content-type");created=1618884473;keyid="test-key"`, ""},
+		{`("date" "@authority" "content-type");created=1618884473;keyid="other-key"`, `keyid "other-key" does not match "test-key"`},
+		{`("date" "@authority" "content-type");created=1618884473;expires=1618884473`, "signature expired at 2021-04-20T02:07:53Z"},
+		{`("date" "@authority" "content-type");alg="rsa-pss-sha512"`, `alg "rsa-pss-sha512" is not supported, expected hmac-sha256`},
+	}
+	for _, tc := range testCases {
+		r := newRequest()
+		sign(r, tc.input)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		var resp signatureResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Error != tc.err || resp.Authenticated != (tc.err == "") {
+			t.Errorf("Expected %s to give error %q, got %q", tc.input, tc.err, resp.Error)
+		}
+	}
+
+	r := newRequest()
+	sign(r, `("date" "@authority" "content-type")`)
+	r.Header.Set("Date", "Wed, 21 Apr 2021 02:07:55 GMT")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	var resp signatureResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(resp.StringToSign, "\"date\": Wed, 21 Apr 2021") {
+		t.Errorf("Expected a modified header to fail with the signature base explained. Status: %d, Body: %s", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("POST", "http://example.com/http-signature/test-key/shared-secret", strings.NewReader("tampered"))
+	r.Header.Set("Content-Digest", "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:")
+	r.Header.Set("Signature-Input", `sig=("content-digest")`)
+	r.Header.Set("Signature", "sig=:AAAA:")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "does not match the body digest") {
+		t.Errorf("Expected a Content-Digest mismatch, got %s", w.Body.String())
+	}
+}
+
+func TestHandleWebhookSignatures(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	handler := newTestServer(t, withTestClock(&now)).Handler()
+
+	stripeSig := func(timestamp int64, body string) string {
+		mac := hmac.New(sha256.New, []byte("whsec_test"))
+		fmt.Fprintf(mac, "%d.%s", timestamp, body)
+		return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	testCases := []struct {
+		target string
+		header string
+		value  string
+		body   string
+		code   int
+	}{
+		// the example from GitHub's webhook documentation
+		{"/webhook/github/It's%20a%20Secret%20to%20Everybody", "X-Hub-Signature-256", "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17", "Hello, World!", http.StatusOK},
+		{"/webhook/github/It's%20a%20Secret%20to%20Everybody", "X-Hub-Signature-256", "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17", "Hello, World?", http.StatusUnauthorized},
+		{"/webhook/github/secret", "X-Hub-Signature", "", "{}", http.StatusUnauthorized},
+		{"/webhook/hmac/key?header=X-Sig&encoding=base64&algorithm=sha256", "X-Sig", "UDH+PZicbRU3oBP6bnOdojRj/a7DtwE32Cjjas4iG9A=", "data", http.StatusOK},
+		{"/webhook/hmac/key?algorithm=md5", "X-Signature", "", "data", http.StatusBadRequest},
+		{"/webhook/stripe/whsec_test", "Stripe-Signature", stripeSig(1600000000-60, `{"id":"evt_1"}`), `{"id":"evt_1"}`, http.StatusOK},
+		{"/webhook/stripe/whsec_test", "Stripe-Signature", stripeSig(1600000000-600, `{"id":"evt_1"}`), `{"id":"evt_1"}`, http.StatusUnauthorized},
+		{"/webhook/stripe/whsec_test?tolerance=0", "Stripe-Signature", stripeSig(1600000000-600, `{"id":"evt_1"}`), `{"id":"evt_1"}`, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest("POST", "http://test.com"+tc.target, strings.NewReader(tc.body))
+		if tc.value != "" {
+			r.Header.Set(tc.header, tc.value)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("Expected %d from %s, got %d: %s", tc.code, tc.target, w.Code, w.Body.String())
+		}
+	}
+}
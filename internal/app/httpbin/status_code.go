@@ -1,28 +1,226 @@
 package httpbin
 
 import (
-	"math/rand"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/gorilla/mux"
 )
 
+// maxStatusSequences caps the number of clients whose position in a
+// ?mode=sequence list is remembered
+const maxStatusSequences = 10000
+
+// statusSequences tracks the next code of each client and code list
+// requested with ?mode=sequence
+type statusSequences struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// advance returns the position of the next code for key and moves it on
+func (s *statusSequences) advance(key string, n int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == nil || len(s.next) >= maxStatusSequences {
+		s.next = make(map[string]int)
+	}
+	i := s.next[key] % n
+	s.next[key] = i + 1
+	return i
+}
+
+// handleStatusCodes responds with one of the codes in the path, picked at
+// random in proportion to their weights or, with ?mode=sequence, in turn
+// for each client
 func (s *Server) handleStatusCodes() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		strCodes := strings.Split(vars["codes"], ",")
-		var codes []int
-		for _, code := range strCodes {
-			i, err := strconv.Atoi(code)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+		codes, err := parseWeightedCodes(mux.Vars(r)["codes"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var code int
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", "random":
+			code = codes.pick(s.random())
+		case "sequence":
+			key := clientIP(r) + " " + r.URL.Path
+			code = codes[s.statusSequences.advance(key, len(codes))].code
+		default:
+			http.Error(w, fmt.Sprintf("invalid mode %q, expected random or sequence", mode), http.StatusBadRequest)
+			return
+		}
+		s.writeStatus(w, r, code)
+	}
+}
+
+// statusBody is the JSON body of a status response
+type statusBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// problemDetails is an RFC 7807 problem+json body
+type problemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance"`
+}
+
+var statusMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// writeStatus writes code with the headers a real server sends with it
+// and, when requested through ?body=json|problem or an Accept of
+// application/problem+json, a body describing it
+func (s *Server) writeStatus(w http.ResponseWriter, r *http.Request, code int) {
+	query := r.URL.Query()
+	format := query.Get("body")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "application/problem+json") {
+		format = "problem"
+	}
+	if format != "" && format != "json" && format != "problem" {
+		http.Error(w, fmt.Sprintf("invalid body %q, expected json or problem", format), http.StatusBadRequest)
+		return
+	}
+
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusUseProxy,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		w.Header().Set("Location", s.path("/get"))
+	case http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Basic realm="Fake Realm"`)
+	case http.StatusProxyAuthRequired:
+		w.Header().Set("Proxy-Authenticate", `Basic realm="Fake Realm"`)
+	case http.StatusMethodNotAllowed:
+		var allowed []string
+		for _, method := range statusMethods {
+			if method != r.Method {
+				allowed = append(allowed, method)
 			}
-			codes = append(codes, i)
 		}
-		rand.Seed(time.Now().Unix())
-		w.WriteHeader(codes[rand.Intn(len(codes))])
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	case http.StatusRequestedRangeNotSatisfiable:
+		w.Header().Set("Content-Range", "bytes */0")
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		retryAfter := query.Get("retry_after")
+		if retryAfter == "" {
+			retryAfter = "1"
+		}
+		w.Header().Set("Retry-After", retryAfter)
+	}
+
+	if format == "" || !bodyAllowed(code) {
+		w.WriteHeader(code)
+		return
+	}
+
+	var body interface{} = statusBody{Status: code, Message: http.StatusText(code)}
+	contentType := "application/json"
+	if format == "problem" {
+		contentType = "application/problem+json"
+		body = problemDetails{
+			Type:     "about:blank",
+			Title:    http.StatusText(code),
+			Status:   code,
+			Detail:   query.Get("detail"),
+			Instance: r.URL.Path,
+		}
+	}
+
+	jsonResp, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonResp = append(jsonResp, "\n"...)
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(jsonResp)
+}
+
+// bodyAllowed reports whether a response with the status code may have a
+// body
+func bodyAllowed(code int) bool {
+	return code >= 200 && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+// clientIP identifies the client of a request by its origin address
+// without the port
+func clientIP(r *http.Request) string {
+	origin := getOrigin(r)
+	if host, _, err := net.SplitHostPort(origin); err == nil {
+		return host
+	}
+	return origin
+}
+
+// weightedCodes are status codes picked at random in proportion to their
+// weights
+type weightedCodes []weightedCode
+
+type weightedCode struct {
+	code   int
+	weight float64
+}
+
+// parseWeightedCodes parses a comma separated list of status codes, each
+// optionally followed by :weight, e.g. 200:0.9,500:0.1. Codes without a
+// weight have a weight of 1.
+func parseWeightedCodes(val string) (weightedCodes, error) {
+	var codes weightedCodes
+	for _, item := range strings.Split(val, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		code, err := strconv.Atoi(parts[0])
+		if err != nil || code < 100 || code > 999 {
+			return nil, fmt.Errorf("invalid status code %q", parts[0])
+		}
+		weight := 1.0
+		if len(parts) == 2 {
+			weight, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+				return nil, fmt.Errorf("invalid weight %q", parts[1])
+			}
+		}
+		codes = append(codes, weightedCode{code: code, weight: weight})
+	}
+	if total := codes.total(); total <= 0 || math.IsInf(total, 0) {
+		return nil, fmt.Errorf("weights must not all be zero or add up to infinity")
+	}
+	return codes, nil
+}
+
+func (c weightedCodes) total() float64 {
+	var total float64
+	for _, wc := range c {
+		total += wc.weight
+	}
+	return total
+}
+
+func (c weightedCodes) pick(rnd *lockedRand) int {
+	n := rnd.Float64() * c.total()
+	picked := 0
+	for _, wc := range c {
+		if wc.weight == 0 {
+			continue
+		}
+		picked = wc.code
+		if n < wc.weight {
+			break
+		}
+		n -= wc.weight
 	}
+	return picked
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/nathanows/httpbin-go/pkg/jsonparser"
 )
 
 var statusCodeServer = &Server{}
@@ -31,3 +32,119 @@ func TestHandleStatusCodes(t *testing.T) {
 		t.Errorf("Response body should be empty, got: %v", string(req.rawResponse))
 	}
 }
+
+func TestHandleStatusCodes_Weighted(t *testing.T) {
+	server := &Server{rand: newLockedRand(1)}
+	counts := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		req := newTestRequest(server.handleStatusCodes(), "http://test.com/status/200:0.9,500:0.1", "GET")
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"codes": "200:0.9,500:0.1"})
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		counts[req.response.Code]++
+	}
+	if counts[200]+counts[500] != 1000 || counts[500] < 50 || counts[500] > 150 {
+		t.Errorf("Expected roughly 10%% 500s, got: %v", counts)
+	}
+}
+
+func TestHandleStatusCodes_Sequence(t *testing.T) {
+	server := &Server{}
+	var got []int
+	for i := 0; i < 4; i++ {
+		req := newTestRequest(server.handleStatusCodes(), "http://test.com/status/503,503,200?mode=sequence", "GET")
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"codes": "503,503,200"})
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		got = append(got, req.response.Code)
+	}
+	if fmt.Sprint(got) != "[503 503 200 503]" {
+		t.Errorf("Expected codes in sequence, got: %v", got)
+	}
+}
+
+func TestHandleStatusCodes_Invalid(t *testing.T) {
+	testCases := []struct {
+		codes string
+		query string
+	}{
+		{"200,abc", ""},
+		{"42", ""},
+		{"200:-1", ""},
+		{"200:NaN,500:1", ""},
+		{"200:Inf", ""},
+		{"200:1e308,500:1e308", ""},
+		{"200:0,500:0", ""},
+		{"200", "?mode=shuffle"},
+	}
+	for _, tc := range testCases {
+		req := newTestRequest(statusCodeServer.handleStatusCodes(), "http://test.com/status/"+tc.codes+tc.query, "GET")
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"codes": tc.codes})
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		if req.response.Code != 400 {
+			t.Errorf("Expected status 400 for %s%s, got: %d", tc.codes, tc.query, req.response.Code)
+		}
+	}
+}
+
+func TestHandleStatusCodes_Headers(t *testing.T) {
+	testCases := []struct {
+		code   string
+		header string
+		value  string
+	}{
+		{"302", "Location", "/get"},
+		{"401", "WWW-Authenticate", `Basic realm="Fake Realm"`},
+		{"407", "Proxy-Authenticate", `Basic realm="Fake Realm"`},
+		{"405", "Allow", "HEAD, POST, PUT, PATCH, DELETE, OPTIONS"},
+		{"416", "Content-Range", "bytes */0"},
+		{"429", "Retry-After", "1"},
+		{"503", "Retry-After", "1"},
+	}
+	for _, tc := range testCases {
+		req := newTestRequest(statusCodeServer.handleStatusCodes(), "http://test.com/status/"+tc.code, "GET")
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"codes": tc.code})
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		if val := req.response.Header().Get(tc.header); val != tc.value {
+			t.Errorf("Expected %s header %q for %s, got: %q", tc.header, tc.value, tc.code, val)
+		}
+	}
+}
+
+func TestHandleStatusCodes_Body(t *testing.T) {
+	testCases := []struct {
+		query       string
+		accept      string
+		contentType string
+		field       string
+		value       string
+	}{
+		{"?body=json", "", "application/json", "message", "Service Unavailable"},
+		{"?body=problem&detail=down", "", "application/problem+json", "detail", "down"},
+		{"", "application/problem+json", "application/problem+json", "title", "Service Unavailable"},
+	}
+	for _, tc := range testCases {
+		req := newTestRequest(statusCodeServer.handleStatusCodes(), "http://test.com/status/503"+tc.query, "GET",
+			testReqStatus([]int{503}), testReqHeaders(map[string][]string{"Accept": {tc.accept}}))
+		req.baseRequest = mux.SetURLVars(req.baseRequest, map[string]string{"codes": "503"})
+		if err := req.make(); err != nil {
+			t.Fatalf("Failed to make request. Err: %v", err)
+		}
+		if val := req.response.Header().Get("Content-Type"); val != tc.contentType {
+			t.Errorf("Expected Content-Type %s, got: %s", tc.contentType, val)
+		}
+		parsed, err := jsonparser.ParseJSON(req.rawResponse)
+		if err != nil {
+			t.Fatalf("Unable to parse returned JSON. Err: %v", err)
+		}
+		if val := parsed.Path(tc.field).String(); val != tc.value {
+			t.Errorf("Expected %s to be %q, got: %q", tc.field, tc.value, val)
+		}
+	}
+}
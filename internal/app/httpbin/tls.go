@@ -0,0 +1,164 @@
+package httpbin
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+type tlsResponse struct {
+	TLS                bool              `json:"tls"`
+	Version            string            `json:"version,omitempty"`
+	CipherSuite        string            `json:"cipher_suite,omitempty"`
+	NegotiatedProtocol string            `json:"alpn,omitempty"`
+	ServerName         string            `json:"sni,omitempty"`
+	Resumed            bool              `json:"resumed,omitempty"`
+	ClientCertificates []certificateInfo `json:"client_certificates,omitempty"`
+	Verified           bool              `json:"client_verified,omitempty"`
+}
+
+type certificateInfo struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
+	EmailAddresses    []string  `json:"email_addresses,omitempty"`
+	IPAddresses       []string  `json:"ip_addresses,omitempty"`
+	URIs              []string  `json:"uris,omitempty"`
+	SHA1Fingerprint   string    `json:"sha1_fingerprint"`
+	SHA256Fingerprint string    `json:"sha256_fingerprint"`
+}
+
+func (s *Server) handleTLS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := newTLSResponse(r.TLS)
+
+		jsonResp, err := json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jsonResp = append(jsonResp, "\n"...)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResp)
+	}
+}
+
+// newTLSResponse describes the TLS connection state of a request, state is
+// nil for plain HTTP
+func newTLSResponse(state *tls.ConnectionState) tlsResponse {
+	if state == nil {
+		return tlsResponse{}
+	}
+	resp := tlsResponse{
+		TLS:                true,
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		ServerName:         state.ServerName,
+		Resumed:            state.DidResume,
+		Verified:           len(state.VerifiedChains) > 0,
+	}
+	for _, cert := range state.PeerCertificates {
+		resp.ClientCertificates = append(resp.ClientCertificates, newCertificateInfo(cert))
+	}
+	return resp
+}
+
+func newCertificateInfo(cert *x509.Certificate) certificateInfo {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	info := certificateInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		DNSNames:          cert.DNSNames,
+		EmailAddresses:    cert.EmailAddresses,
+		SHA1Fingerprint:   hex.EncodeToString(sha1Sum[:]),
+		SHA256Fingerprint: hex.EncodeToString(sha256Sum[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
+
+// tlsConfig builds the TLS configuration from either the configured
+// certificate files or a freshly generated self-signed CA
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	cfg := s.cfg()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	var generatedCA *x509.Certificate
+	if cfg.TLSSelfSigned {
+		certs, err := generateSelfSignedCerts(cfg.TLSHosts)
+		if err != nil {
+			return nil, err
+		}
+		if err := certs.writeFiles(cfg.TLSDir); err != nil {
+			return nil, fmt.Errorf("unable to write certificates: %v", err)
+		}
+		s.logf("Wrote self-signed CA, server and client certificates to %s", cfg.TLSDir)
+		tlsConfig.Certificates = []tls.Certificate{certs.server}
+		generatedCA = certs.ca
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pemCerts, err := ioutil.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client CA: %v", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", cfg.TLSClientCAFile)
+		}
+	} else if generatedCA != nil {
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		tlsConfig.ClientCAs.AddCert(generatedCA)
+	}
+
+	clientAuth := cfg.TLSClientAuth
+	if clientAuth == "" {
+		clientAuth = "request"
+		if tlsConfig.ClientCAs != nil {
+			clientAuth = "verify-if-given"
+		}
+	}
+	authType, ok := clientAuthTypes[clientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown client auth mode: %s", clientAuth)
+	}
+	tlsConfig.ClientAuth = authType
+
+	return tlsConfig, nil
+}
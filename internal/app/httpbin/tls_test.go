@@ -0,0 +1,96 @@
+package httpbin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nathanows/httpbin-go/pkg/jsonparser"
+)
+
+func TestHandleTLS_Plaintext(t *testing.T) {
+	target := "http://test.com/tls"
+	req := newTestRequest(reqInspectServer.handleTLS(), target, "GET")
+	if err := req.make(); err != nil {
+		t.Errorf("Failed to make request. Err: %v", err)
+	}
+
+	if err := req.validateStatusCode(); err != nil {
+		t.Errorf("Failed request base validations. Failure: %v", err)
+	}
+
+	if val := req.parsedJSON.Path("tls").Data(); val != false {
+		t.Errorf("Expected 'tls' to be 'false', got: %v", val)
+	}
+}
+
+func TestHandleTLS_MutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpbin-tls")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := DefaultConfig()
+	cfg.TLSSelfSigned = true
+	cfg.TLSDir = dir
+	server := &Server{config: cfg}
+
+	tlsConfig, err := server.tlsConfig()
+	if err != nil {
+		t.Fatalf("Failed to build TLS config. Err: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(server.handleTLS())
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	caPEM, err := ioutil.ReadFile(filepath.Join(dir, caCertFile))
+	if err != nil {
+		t.Fatalf("Failed to read generated CA. Err: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, clientCertFile), filepath.Join(dir, clientKeyFile))
+	if err != nil {
+		t.Fatalf("Failed to load generated client certificate. Err: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+		ServerName:   "localhost",
+	}}}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	parsed, err := jsonparser.ParseJSON(body)
+	if err != nil {
+		t.Fatalf("Unable to parse returned JSON. Err: %v", err)
+	}
+
+	if val := parsed.Path("tls").Data(); val != true {
+		t.Errorf("Expected 'tls' to be 'true', got: %v", val)
+	}
+	if val := parsed.Path("sni").String(); val != "localhost" {
+		t.Errorf("Expected sni to be localhost, got: %s", val)
+	}
+	if val := parsed.Path("client_verified").Data(); val != true {
+		t.Errorf("Expected client certificate to be verified, got: %v", val)
+	}
+	if val := parsed.Path("client_certificates.subject").Data(); val == nil {
+		t.Errorf("Expected the client certificate chain to be reported")
+	} else if subjects := val.([]interface{}); subjects[0] != "CN=httpbin-go client,O=httpbin-go" {
+		t.Errorf("Unexpected client certificate subject: %v", subjects[0])
+	}
+}
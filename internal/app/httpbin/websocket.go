@@ -0,0 +1,291 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxWebSocketStream caps the number of messages of /ws/stream/{n}
+	maxWebSocketStream = 1000
+
+	// webSocketCloseTimeout is how long the server waits for the client to
+	// answer a close frame before dropping the connection
+	webSocketCloseTimeout = time.Second
+)
+
+// webSocketOptions are the misbehaviour knobs of the WebSocket endpoints,
+// read from the query string
+type webSocketOptions struct {
+	subprotocols    []string
+	compress        bool
+	pingInterval    time.Duration
+	ignorePings     bool
+	closeCode       int
+	closeReason     string
+	closeAfter      int
+	disconnectAfter int
+	delay           float64
+	binary          bool
+}
+
+func parseWebSocketOptions(r *http.Request) (webSocketOptions, error) {
+	query := r.URL.Query()
+	opts := webSocketOptions{
+		subprotocols: splitList(query.Get("subprotocols")),
+		compress:     query.Get("compress") == "true",
+		ignorePings:  query.Get("ignore_pings") == "true",
+		binary:       query.Get("binary") == "true",
+		closeCode:    websocket.CloseNormalClosure,
+		closeReason:  query.Get("close_reason"),
+	}
+
+	if val := query.Get("ping_interval"); val != "" {
+		d, err := parseSeconds(val)
+		if err != nil || d <= 0 {
+			return opts, fmt.Errorf("invalid ping_interval %q", val)
+		}
+		opts.pingInterval = d
+	}
+	if val := query.Get("close_code"); val != "" {
+		code, err := strconv.Atoi(val)
+		if err != nil || !sendableCloseCode(code) {
+			return opts, fmt.Errorf("invalid close_code %q, expected 1000-1003, 1007-1014 or 3000-4999", val)
+		}
+		opts.closeCode = code
+	}
+	if len(opts.closeReason) > 123 {
+		return opts, fmt.Errorf("close_reason must be at most 123 bytes")
+	}
+	for param, n := range map[string]*int{"close_after": &opts.closeAfter, "disconnect_after": &opts.disconnectAfter} {
+		if val := query.Get(param); val != "" {
+			parsed, err := strconv.Atoi(val)
+			if err != nil || parsed < 0 {
+				return opts, fmt.Errorf("invalid %s %q", param, val)
+			}
+			*n = parsed
+		}
+	}
+	delay, err := parseURLFloat(query.Get("delay"), "0")
+	if err != nil || delay < 0 {
+		return opts, fmt.Errorf("invalid delay %q", query.Get("delay"))
+	}
+	opts.delay = delay
+	return opts, nil
+}
+
+// sendableCloseCode reports whether a close code may be sent in a close
+// frame: RFC 6455 reserves 1004, 1005, 1006 and 1015, and the rest of
+// 1000-2999 is unassigned
+func sendableCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+// webSocketConn is an upgraded connection with a goroutine reading from it
+// so control frames are answered while the handler writes
+type webSocketConn struct {
+	*websocket.Conn
+	messages  chan webSocketMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Close closes the connection and stops the goroutines serving it
+func (ws *webSocketConn) Close() error {
+	ws.closeOnce.Do(func() { close(ws.closed) })
+	return ws.Conn.Close()
+}
+
+type webSocketMessage struct {
+	messageType int
+	data        []byte
+}
+
+// upgradeWebSocket upgrades the request, negotiating the subprotocols and
+// compression of the options, and starts sending pings at the requested
+// interval. It returns nil when the upgrade failed, in which case an error
+// response has been written.
+func (s *Server) upgradeWebSocket(w http.ResponseWriter, r *http.Request, opts webSocketOptions) *webSocketConn {
+	upgrader := websocket.Upgrader{
+		Subprotocols:      opts.subprotocols,
+		EnableCompression: opts.compress,
+		CheckOrigin:       func(r *http.Request) bool { return true },
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil
+	}
+	if maxBodySize := s.cfg().MaxBodySize; maxBodySize > 0 {
+		conn.SetReadLimit(maxBodySize)
+	}
+	if opts.ignorePings {
+		conn.SetPingHandler(func(string) error { return nil })
+	}
+
+	ws := &webSocketConn{Conn: conn, messages: make(chan webSocketMessage), closed: make(chan struct{})}
+	go func() {
+		defer close(ws.messages)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			select {
+			case ws.messages <- webSocketMessage{messageType, data}:
+			case <-ws.closed:
+				return
+			}
+		}
+	}()
+
+	if opts.pingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(opts.pingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ws.closed:
+					return
+				case <-ticker.C:
+					if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(opts.pingInterval)); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+	return ws
+}
+
+// finish ends the connection after the given number of messages: closing
+// it abruptly when disconnect_after was reached, otherwise with a close
+// frame once close_after was reached or the stream is complete. It
+// reports whether the connection was ended.
+func (ws *webSocketConn) finish(opts webSocketOptions, sent int, complete bool) bool {
+	switch {
+	case opts.disconnectAfter > 0 && sent >= opts.disconnectAfter:
+		ws.UnderlyingConn().Close()
+	case complete || (opts.closeAfter > 0 && sent >= opts.closeAfter):
+		msg := websocket.FormatCloseMessage(opts.closeCode, opts.closeReason)
+		ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(webSocketCloseTimeout))
+		ws.drain()
+	default:
+		return false
+	}
+	return true
+}
+
+// drain discards messages until the client answers the close frame or the
+// close timeout passes
+func (ws *webSocketConn) drain() {
+	timeout := time.After(webSocketCloseTimeout)
+	for {
+		select {
+		case _, ok := <-ws.messages:
+			if !ok {
+				ws.Close()
+				return
+			}
+		case <-timeout:
+			ws.Close()
+			return
+		}
+	}
+}
+
+// handleWebSocketEcho echoes text and binary messages back to the client
+func (s *Server) handleWebSocketEcho() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := parseWebSocketOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ws := s.upgradeWebSocket(w, r, opts)
+		if ws == nil {
+			return
+		}
+		defer s.trackStream("ws-echo")()
+		defer ws.Close()
+
+		sent := 0
+		for msg := range ws.messages {
+			if err := s.delayRequest(opts.delay); err != nil {
+				return
+			}
+			if err := ws.WriteMessage(msg.messageType, msg.data); err != nil {
+				return
+			}
+			sent++
+			if ws.finish(opts, sent, false) {
+				return
+			}
+		}
+	}
+}
+
+type webSocketStreamMessage struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+// handleWebSocketStream sends n JSON messages, ?delay= seconds apart, then
+// closes the connection
+func (s *Server) handleWebSocketStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := parseURLFloat(mux.Vars(r)["n"], "")
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid number of messages", http.StatusBadRequest)
+			return
+		}
+		n = math.Min(n, maxWebSocketStream)
+		opts, err := parseWebSocketOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ws := s.upgradeWebSocket(w, r, opts)
+		if ws == nil {
+			return
+		}
+		defer s.trackStream("ws-stream")()
+		defer ws.Close()
+		// discard what the client sends so its control frames are answered
+		go func() {
+			for range ws.messages {
+			}
+		}()
+
+		messageType := websocket.TextMessage
+		if opts.binary {
+			messageType = websocket.BinaryMessage
+		}
+		for sent := 0; !ws.finish(opts, sent, sent >= int(n)); sent++ {
+			if sent > 0 {
+				if err := s.delayRequest(opts.delay); err != nil {
+					return
+				}
+			}
+			msg, err := json.Marshal(webSocketStreamMessage{ID: sent, Time: s.now().UTC()})
+			if err != nil {
+				return
+			}
+			if err := ws.WriteMessage(messageType, msg); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package httpbin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSocketConfig lets tests delay messages
+var webSocketConfig = &Config{MaxDelay: Duration(time.Second)}
+
+func dialWebSocket(t *testing.T, ts *httptest.Server, path string, dialer *websocket.Dialer) *websocket.Conn {
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial %s. Err: %v, Response: %v", path, err, resp)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestHandleWebSocketEcho(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(webSocketConfig))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{Subprotocols: []string{"v2", "v1"}, EnableCompression: true}
+	conn := dialWebSocket(t, ts, "/ws/echo?subprotocols=v1&compress=true&close_after=2&close_code=4001&close_reason=bye", dialer)
+	defer conn.Close()
+	if conn.Subprotocol() != "v1" {
+		t.Errorf("Expected subprotocol v1 to be negotiated, got %q", conn.Subprotocol())
+	}
+
+	for _, msg := range []struct {
+		messageType int
+		data        string
+	}{{websocket.TextMessage, "hello"}, {websocket.BinaryMessage, "\x00\x01"}} {
+		conn.WriteMessage(msg.messageType, []byte(msg.data))
+		messageType, data, err := conn.ReadMessage()
+		if err != nil || messageType != msg.messageType || string(data) != msg.data {
+			t.Errorf("Expected %q echoed as type %d, got %q as type %d. Err: %v", msg.data, msg.messageType, data, messageType, err)
+		}
+	}
+
+	_, _, err := conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != 4001 || closeErr.Text != "bye" {
+		t.Errorf("Expected close 4001 bye after two messages, got %v", err)
+	}
+}
+
+func TestHandleWebSocketStream(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(webSocketConfig))
+	defer ts.Close()
+
+	conn := dialWebSocket(t, ts, "/ws/stream/3?binary=true", nil)
+	defer conn.Close()
+	for i := 0; i < 3; i++ {
+		messageType, data, err := conn.ReadMessage()
+		var msg webSocketStreamMessage
+		if err != nil || messageType != websocket.BinaryMessage || json.Unmarshal(data, &msg) != nil || msg.ID != i {
+			t.Fatalf("Expected binary message %d, got %q as type %d. Err: %v", i, data, messageType, err)
+		}
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected a normal close after the stream, got %v", err)
+	}
+
+	conn = dialWebSocket(t, ts, "/ws/stream/10?disconnect_after=2", nil)
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("Expected message %d before the disconnect. Err: %v", i, err)
+		}
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseAbnormalClosure) {
+		t.Errorf("Expected an abnormal close, got %v", err)
+	}
+}
+
+func TestWebSocketPings(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(webSocketConfig))
+	defer ts.Close()
+
+	conn := dialWebSocket(t, ts, "/ws/echo?ping_interval=0.05", nil)
+	defer conn.Close()
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go conn.ReadMessage()
+	select {
+	case <-pinged:
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected a ping from the server")
+	}
+}
+
+func TestWebSocketInvalidOptions(t *testing.T) {
+	ts := newHTTPTestServer(t, WithConfig(webSocketConfig))
+	defer ts.Close()
+
+	for _, path := range []string{"/ws/echo?close_code=999", "/ws/echo?close_code=1005", "/ws/stream/3?close_code=1015", "/ws/echo?close_code=2000", "/ws/echo?ping_interval=soon", "/ws/stream/3?close_after=-1"} {
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+path, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected with 400, got %v", path, resp)
+		}
+	}
+}
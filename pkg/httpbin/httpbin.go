@@ -0,0 +1,121 @@
+// Package httpbin exposes httpbin-go as an http.Handler so it can be served
+// in-process, for example from tests:
+//
+//	ts := httptest.NewServer(httpbin.New())
+//	defer ts.Close()
+//	resp, err := http.Get(ts.URL + "/get")
+package httpbin
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nathanows/httpbin-go/internal/app/httpbin"
+)
+
+// Option configures the handler returned by New
+type Option func(*options)
+
+type options struct {
+	config     *httpbin.Config
+	serverOpts []httpbin.Option
+}
+
+// WithPrefix mounts all routes under prefix, e.g. "/httpbin"
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.serverOpts = append(o.serverOpts, httpbin.WithPathPrefix(prefix))
+	}
+}
+
+// WithRouteGroups enables only the named route groups, such as "http",
+// "status" or "dynamic-data". All groups are enabled by default.
+func WithRouteGroups(groups ...string) Option {
+	return func(o *options) {
+		o.config.RouteGroups = groups
+	}
+}
+
+// WithClock replaces time.Now as the source of the current time, used for
+// cookie expiry and Last-Modified headers
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.serverOpts = append(o.serverOpts, httpbin.WithClock(now))
+	}
+}
+
+// WithSeed seeds the random source behind /status and /bytes so responses
+// are reproducible between runs
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.serverOpts = append(o.serverOpts, httpbin.WithSeed(seed))
+	}
+}
+
+// WithLogger sets the logger for server messages and errors
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.serverOpts = append(o.serverOpts, httpbin.WithLogger(logger))
+	}
+}
+
+// WithMultiValue emits repeated args, form fields and headers as arrays
+func WithMultiValue() Option {
+	return func(o *options) {
+		o.config.MultiValue = true
+	}
+}
+
+// WithAdhocTemplates lets /template render the template in its tmpl query
+// parameter, which is disabled by default
+func WithAdhocTemplates() Option {
+	return func(o *options) {
+		o.config.AdhocTemplates = true
+	}
+}
+
+// WithFaultInjection applies the faults requested by the X-Httpbin-Fault
+// header or _fault query parameter, which is disabled by default
+func WithFaultInjection() Option {
+	return func(o *options) {
+		o.config.FaultInjection = true
+	}
+}
+
+// WithMaxBodySize rejects request bodies larger than n bytes
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.config.MaxBodySize = n
+	}
+}
+
+// WithAccessLog writes an access log line for every request to w in the
+// given format: "common", "combined" or "json". Access logging is off by
+// default.
+func WithAccessLog(format string, w io.Writer) Option {
+	return func(o *options) {
+		o.config.AccessLog = format
+		o.serverOpts = append(o.serverOpts, httpbin.WithAccessLogOutput(w))
+	}
+}
+
+// New returns an http.Handler serving the httpbin endpoints. It panics if
+// the options are invalid, such as an unknown route group.
+func New(opts ...Option) http.Handler {
+	o := &options{config: httpbin.DefaultConfig()}
+	o.config.AccessLog = httpbin.AccessLogNone
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	serverOpts := append([]httpbin.Option{httpbin.WithConfig(o.config)}, o.serverOpts...)
+	server, err := httpbin.NewServer(mux.NewRouter().StrictSlash(true), serverOpts...)
+	if err != nil {
+		panic(fmt.Sprintf("httpbin: %v", err))
+	}
+	return server.Handler()
+}
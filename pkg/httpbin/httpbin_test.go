@@ -0,0 +1,116 @@
+package httpbin_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nathanows/httpbin-go/pkg/httpbin"
+	"github.com/nathanows/httpbin-go/pkg/jsonparser"
+)
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, []byte) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to make request. Err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response. Err: %v", err)
+	}
+	return resp, body
+}
+
+func TestNew(t *testing.T) {
+	ts := httptest.NewServer(httpbin.New())
+	defer ts.Close()
+
+	resp, body := get(t, ts.Client(), ts.URL+"/get?animal=dog")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got: %d", resp.StatusCode)
+	}
+
+	parsed, err := jsonparser.ParseJSON(body)
+	if err != nil {
+		t.Fatalf("Unable to parse returned JSON. Err: %v", err)
+	}
+	if val := parsed.Path("args.animal").String(); val != "dog" {
+		t.Errorf("Expected args.animal to be dog, got: %s", val)
+	}
+}
+
+func TestNew_PrefixAndRouteGroups(t *testing.T) {
+	ts := httptest.NewServer(httpbin.New(httpbin.WithPrefix("/httpbin"), httpbin.WithRouteGroups("http")))
+	defer ts.Close()
+
+	if resp, _ := get(t, ts.Client(), ts.URL+"/httpbin/get"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected /httpbin/get to return 200, got: %d", resp.StatusCode)
+	}
+	if resp, _ := get(t, ts.Client(), ts.URL+"/get"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected /get to return 404, got: %d", resp.StatusCode)
+	}
+	if resp, _ := get(t, ts.Client(), ts.URL+"/httpbin/uuid"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected disabled route groups to return 404, got: %d", resp.StatusCode)
+	}
+}
+
+func TestNew_Seed(t *testing.T) {
+	var bodies [][]byte
+	for i := 0; i < 2; i++ {
+		ts := httptest.NewServer(httpbin.New(httpbin.WithSeed(42)))
+		_, body := get(t, ts.Client(), ts.URL+"/bytes/32")
+		ts.Close()
+		bodies = append(bodies, body)
+	}
+
+	if !bytes.Equal(bodies[0], bodies[1]) {
+		t.Errorf("Expected servers with the same seed to return the same bytes")
+	}
+}
+
+func TestNew_Clock(t *testing.T) {
+	fixed := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ts := httptest.NewServer(httpbin.New(httpbin.WithClock(func() time.Time { return fixed })))
+	defer ts.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, _ := get(t, client, ts.URL+"/cookies/set/animal/dog")
+
+	cookie := resp.Header.Get("Set-Cookie")
+	if !strings.Contains(cookie, "Expires=Wed, 01 Jan 2020 00:53:20 GMT") {
+		t.Errorf("Expected cookie expiry to use the clock, got: %s", cookie)
+	}
+}
+
+func TestNew_FaultInjection(t *testing.T) {
+	for _, tc := range []struct {
+		opts []httpbin.Option
+		code int
+	}{
+		{nil, http.StatusOK},
+		{[]httpbin.Option{httpbin.WithFaultInjection()}, http.StatusServiceUnavailable},
+	} {
+		ts := httptest.NewServer(httpbin.New(tc.opts...))
+		resp, _ := get(t, ts.Client(), ts.URL+"/get?_fault=status=503")
+		ts.Close()
+		if resp.StatusCode != tc.code {
+			t.Errorf("Expected %d with %d options, got: %d", tc.code, len(tc.opts), resp.StatusCode)
+		}
+	}
+}
+
+func TestNew_UnknownRouteGroup(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected New to panic for an unknown route group")
+		}
+	}()
+	httpbin.New(httpbin.WithRouteGroups("nope"))
+}